@@ -0,0 +1,139 @@
+package wrouter
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat identifies the format of the lines written by the access log.
+// See Configuration.AccessLog
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon writes lines in the Common Log Format, followed by the matched route path and the
+	// controller action
+	AccessLogCommon AccessLogFormat = iota
+	// AccessLogCombined writes lines in the Combined Log Format, followed by the matched route path and the
+	// controller action
+	AccessLogCombined
+	// AccessLogJSON writes one JSON object per line
+	AccessLogJSON
+)
+
+// clfTimeFormat is the time format used by the Common and Combined Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEntry represents one line of the access log
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	Duration   float64   `json:"duration_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Route      string    `json:"route,omitempty"`
+	Action     string    `json:"action,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// accessLogger writes access log entries to the writer configured in Configuration.AccessLog. Writes are
+// serialized, so that lines of concurrent requests are not mixed up.
+type accessLogger struct {
+	mu sync.Mutex
+}
+
+func (l *accessLogger) log(cfg *Configuration, w *responseWriter, h *http.Request, route *Route, start time.Time) {
+	entry := createAccessLogEntry(w, h, route, start)
+
+	var line []byte
+	switch cfg.AccessLog.Format {
+	case AccessLogJSON:
+		line, _ = json.Marshal(entry)
+	case AccessLogCombined:
+		line = []byte(entry.common() + " " + quote(entry.Referer) + " " + quote(entry.UserAgent) + " " +
+			entry.routeInfo())
+	default:
+		line = []byte(entry.common() + " " + entry.routeInfo())
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	cfg.AccessLog.Writer.Write(line)
+	l.mu.Unlock()
+}
+
+func createAccessLogEntry(w *responseWriter, h *http.Request, route *Route, start time.Time) *accessLogEntry {
+	e := &accessLogEntry{
+		Time:       start,
+		RemoteAddr: h.RemoteAddr,
+		Method:     h.Method,
+		URI:        h.URL.RequestURI(),
+		Proto:      h.Proto,
+		Status:     w.Status(),
+		Bytes:      w.Size(),
+		Duration:   float64(time.Since(start)) / float64(time.Millisecond),
+		Referer:    h.Referer(),
+		UserAgent:  h.UserAgent(),
+		RequestID:  RequestIDFromContext(h.Context()),
+	}
+
+	if host, _, err := net.SplitHostPort(h.RemoteAddr); err == nil {
+		e.RemoteAddr = host
+	}
+	if user, _, ok := h.BasicAuth(); ok {
+		e.User = user
+	}
+	// A request that has not written anything, is answered with 200 by net/http
+	if e.Status == 0 {
+		e.Status = http.StatusOK
+	}
+	if route != nil {
+		e.Route = "/" + route.Path
+		e.Action = route.actionName()
+	}
+	return e
+}
+
+// common returns the entry in the Common Log Format
+func (e *accessLogEntry) common() string {
+	bytes := "-"
+	if e.Bytes != 0 {
+		bytes = strconv.Itoa(e.Bytes)
+	}
+	return dash(e.RemoteAddr) + " - " + dash(e.User) + " [" + e.Time.Format(clfTimeFormat) + "] " +
+		quote(e.Method+" "+e.URI+" "+e.Proto) + " " + strconv.Itoa(e.Status) + " " + bytes
+}
+
+// routeInfo returns the matched route, the action and the request ID if any, which are appended to the
+// Common and Combined Log Format
+func (e *accessLogEntry) routeInfo() string {
+	info := quote(e.Route) + " " + quote(e.Action)
+	if e.RequestID != "" {
+		info += " " + quote(e.RequestID)
+	}
+	return info
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func quote(s string) string {
+	if s == "" {
+		return "\"-\""
+	}
+	return "\"" + strings.Replace(strings.Replace(s, "\\", "\\\\", -1), "\"", "\\\"", -1) + "\""
+}
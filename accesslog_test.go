@@ -0,0 +1,52 @@
+package wrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+	rt.AddInjector(new(tMockUserInjector))
+
+	buf := new(bytes.Buffer)
+	rt.Configuration.AccessLog.Enabled = true
+	rt.Configuration.AccessLog.Format = AccessLogJSON
+	rt.Configuration.AccessLog.Writer = buf
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tmock/another", nil))
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/doesntexist", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log lines, got %d", len(lines))
+	}
+
+	entry := new(accessLogEntry)
+	if err := json.Unmarshal([]byte(lines[0]), entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != http.StatusOK || entry.Route != "/tmock/another" ||
+		entry.Action != "tMockController.AnotherAction" {
+		t.Errorf("Unexpected access log entry: %s", lines[0])
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != http.StatusNotFound || entry.Bytes != len("Not Found") {
+		t.Errorf("Unexpected access log entry: %s", lines[1])
+	}
+
+	buf.Reset()
+	rt.Configuration.AccessLog.Format = AccessLogCommon
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tmock/another?x=1", nil))
+	if !strings.Contains(buf.String(), "\"GET /tmock/another?x=1 HTTP/1.1\" 200 - \"/tmock/another\"") {
+		t.Errorf("Unexpected common log line: %s", buf.String())
+	}
+}
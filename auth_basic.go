@@ -0,0 +1,123 @@
+package wrouter
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"reflect"
+	"strings"
+)
+
+// CredentialChecker verifies the credentials of HTTP Basic authentication. It returns the identity of the
+// user and true, if the credentials are valid.
+type CredentialChecker interface {
+	CheckCredentials(user, password string) (Identity, bool)
+}
+
+// CredentialCheckerFunc is an adapter to allow the use of ordinary functions as CredentialChecker
+type CredentialCheckerFunc func(user, password string) (Identity, bool)
+
+// CheckCredentials implements the CredentialChecker interface
+func (f CredentialCheckerFunc) CheckCredentials(user, password string) (Identity, bool) {
+	return f(user, password)
+}
+
+// StaticCredentials is a CredentialChecker for a fixed set of users, mapping user names to passwords.
+// Passwords are compared in constant time. Valid credentials result in a *BasicIdentity.
+type StaticCredentials map[string]string
+
+// CheckCredentials implements the CredentialChecker interface
+func (c StaticCredentials) CheckCredentials(user, password string) (Identity, bool) {
+	expected, exists := c[user]
+	if !SecureCompare(password, expected) || !exists {
+		return nil, false
+	}
+	return &BasicIdentity{User: user}, true
+}
+
+// SecureCompare compares two strings in constant time, without leaking their length
+func SecureCompare(given, expected string) bool {
+	g := sha256.Sum256([]byte(given))
+	e := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(g[:], e[:]) == 1
+}
+
+// BasicIdentity is the Identity created by StaticCredentials
+type BasicIdentity struct {
+	User  string
+	Roles []string
+}
+
+// Name implements the Identity interface
+func (b *BasicIdentity) Name() string { return b.User }
+
+// HasRole implements the Identity interface
+func (b *BasicIdentity) HasRole(role string) bool {
+	for _, r := range b.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope implements the Identity interface. Basic identities have no scopes.
+func (b *BasicIdentity) HasScope(scope string) bool { return false }
+
+// BasicAuth authenticates requests by HTTP Basic authentication. It is an IdentityProvider to be used with
+// policies (see Router.IdentityProvider), and an Injector providing the identity to actions.
+type BasicAuth struct {
+	// Realm is sent in the WWW-Authenticate header of 401 responses
+	Realm string
+	// Checker verifies the credentials
+	Checker CredentialChecker
+	// InjectType is the type name the identity is injected as. Checkers returning their own identity type
+	// should set it accordingly, e.g. "*myapp.User".
+	//
+	// Default: *wrouter.BasicIdentity
+	InjectType string
+}
+
+// NewBasicAuth creates a BasicAuth with the given realm and credential checker
+func NewBasicAuth(realm string, checker CredentialChecker) *BasicAuth {
+	return &BasicAuth{Realm: realm, Checker: checker, InjectType: "*wrouter.BasicIdentity"}
+}
+
+// Identify implements the IdentityProvider interface
+func (b *BasicAuth) Identify(ctx *InjectorContext) Identity {
+	user, password, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return nil
+	}
+	id, ok := b.Checker.CheckCredentials(user, password)
+	if !ok {
+		return nil
+	}
+	return id
+}
+
+// Challenge implements the Challenger interface
+func (b *BasicAuth) Challenge() string {
+	return "Basic realm=" + quoteParam(b.Realm) + ", charset=\"UTF-8\""
+}
+
+// Supports implements the Injector interface
+func (b *BasicAuth) Supports(t string) bool {
+	return t == b.InjectType
+}
+
+// Get implements the Injector interface. It returns nil for requests without valid credentials. Identities
+// resolved before are reused if they are of the InjectType, e.g. not if they are the Claims of a BearerAuth.
+func (b *BasicAuth) Get(ctx *InjectorContext) interface{} {
+	if id := IdentityFromContext(ctx.Request.Context()); id != nil && reflect.TypeOf(id).String() == b.InjectType {
+		return id
+	}
+	if id := b.Identify(ctx); id != nil {
+		return id
+	}
+	return nil
+}
+
+// quoteParam quotes the value of an authentication parameter
+func quoteParam(s string) string {
+	return "\"" + strings.Replace(strings.Replace(s, "\\", "\\\\", -1), "\"", "\\\"", -1) + "\""
+}
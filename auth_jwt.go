@@ -0,0 +1,388 @@
+package wrouter
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Errors returned by JWTVerifier.Verify
+var (
+	// MalformedTokenError indicates that the token is not a valid JWS compact serialization
+	MalformedTokenError = errors.New("Malformed token")
+	// UnsupportedAlgorithmError indicates that the token is signed by an algorithm which is not allowed
+	UnsupportedAlgorithmError = errors.New("Unsupported token algorithm")
+	// UnknownKeyError indicates that no key for the token could be found
+	UnknownKeyError = errors.New("Unknown token key")
+	// InvalidSignatureError indicates that the signature of the token does not match
+	InvalidSignatureError = errors.New("Invalid token signature")
+	// TokenExpiredError indicates that the token has expired (exp)
+	TokenExpiredError = errors.New("Token expired")
+	// TokenNotYetValidError indicates that the token is not valid yet (nbf)
+	TokenNotYetValidError = errors.New("Token not valid yet")
+	// InvalidAudienceError indicates that the token is not issued for the configured audience (aud)
+	InvalidAudienceError = errors.New("Invalid token audience")
+	// InvalidIssuerError indicates that the token is not issued by the configured issuer (iss)
+	InvalidIssuerError = errors.New("Invalid token issuer")
+)
+
+// Claims contains the claims of a verified JWT. It implements Identity: the name is the subject (sub), the
+// roles are taken from the "roles" claim, the scopes from the space separated "scope" or the "scp" claim.
+// Claims can be injected into actions by an argument of type wrouter.Claims.
+type Claims map[string]interface{}
+
+// Subject returns the sub claim
+func (c Claims) Subject() string { return c.stringClaim("sub") }
+
+// Issuer returns the iss claim
+func (c Claims) Issuer() string { return c.stringClaim("iss") }
+
+// Audience returns the aud claim, which may be a string or a list of strings
+func (c Claims) Audience() []string { return c.listClaim("aud") }
+
+// ExpiresAt returns the exp claim. It is the zero time, if the claim is missing.
+func (c Claims) ExpiresAt() time.Time { return c.timeClaim("exp") }
+
+// NotBefore returns the nbf claim. It is the zero time, if the claim is missing.
+func (c Claims) NotBefore() time.Time { return c.timeClaim("nbf") }
+
+// Name implements the Identity interface
+func (c Claims) Name() string { return c.Subject() }
+
+// HasRole implements the Identity interface
+func (c Claims) HasRole(role string) bool {
+	return containsString(c.listClaim("roles"), role)
+}
+
+// HasScope implements the Identity interface
+func (c Claims) HasScope(scope string) bool {
+	if s := c.stringClaim("scope"); s != "" {
+		return containsString(strings.Fields(s), scope)
+	}
+	return containsString(c.listClaim("scp"), scope)
+}
+
+func (c Claims) stringClaim(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+func (c Claims) listClaim(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func (c Claims) timeClaim(name string) time.Time {
+	switch v := c[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return time.Unix(i, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// KeySet provides the keys to verify tokens. For HS256, a []byte secret is expected, for RS256 an
+// *rsa.PublicKey. The key ID (kid) of the token header may be empty.
+type KeySet interface {
+	Key(kid, alg string) (interface{}, error)
+}
+
+// StaticKeys is a KeySet with one HMAC secret and one RSA public key, used for all tokens regardless of
+// their key ID
+type StaticKeys struct {
+	HMACSecret   []byte
+	RSAPublicKey *rsa.PublicKey
+}
+
+// Key implements the KeySet interface
+func (k *StaticKeys) Key(kid, alg string) (interface{}, error) {
+	switch {
+	case alg == "HS256" && len(k.HMACSecret) != 0:
+		return k.HMACSecret, nil
+	case alg == "RS256" && k.RSAPublicKey != nil:
+		return k.RSAPublicKey, nil
+	}
+	return nil, UnknownKeyError
+}
+
+// JWKS is a KeySet read from a JSON Web Key Set. RSA ("RSA") and symmetric ("oct") keys are supported.
+type JWKS struct {
+	keys map[string]interface{}
+}
+
+// jwk contains the fields of a JSON Web Key used by JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// LoadJWKSFile reads a JSON Web Key Set from a local file
+func LoadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys of unsupported types and keys not meant for signatures are skipped.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	jwks := &JWKS{keys: make(map[string]interface{})}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil {
+				return nil, errors.New("Invalid RSA key " + key.Kid + " in JWKS")
+			}
+			jwks.keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "oct":
+			// An empty secret would allow anyone to sign tokens
+			k, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(k) == 0 {
+				return nil, errors.New("Invalid symmetric key " + key.Kid + " in JWKS")
+			}
+			jwks.keys[key.Kid] = k
+		}
+	}
+	return jwks, nil
+}
+
+// Key implements the KeySet interface. The key type must match the algorithm.
+func (j *JWKS) Key(kid, alg string) (interface{}, error) {
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, UnknownKeyError
+	}
+	switch key.(type) {
+	case []byte:
+		if alg == "HS256" {
+			return key, nil
+		}
+	case *rsa.PublicKey:
+		if alg == "RS256" {
+			return key, nil
+		}
+	}
+	return nil, UnknownKeyError
+}
+
+// JWTVerifier verifies HS256 and RS256 signed JSON Web Tokens against local keys, and checks the exp, nbf,
+// aud and iss claims.
+type JWTVerifier struct {
+	// Keys provides the verification keys
+	Keys KeySet
+	// Audience when set, must be contained in the aud claim
+	Audience string
+	// Issuer when set, must be equal to the iss claim
+	Issuer string
+	// Leeway is the tolerated clock skew for exp and nbf
+	Leeway time.Duration
+	// Algorithms contains the allowed algorithms.
+	//
+	// Default: HS256 and RS256
+	Algorithms []string
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Verify verifies a token in the JWS compact serialization and returns its claims
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, MalformedTokenError
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, MalformedTokenError
+	}
+
+	algorithms := v.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"HS256", "RS256"}
+	}
+	if !containsString(algorithms, header.Alg) {
+		return nil, UnsupportedAlgorithmError
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, MalformedTokenError
+	}
+	key, err := v.Keys.Key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := make(Claims)
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, MalformedTokenError
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) validate(claims Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if exp := claims.ExpiresAt(); !exp.IsZero() && !now.Before(exp.Add(v.Leeway)) {
+		return TokenExpiredError
+	}
+	if nbf := claims.NotBefore(); !nbf.IsZero() && now.Add(v.Leeway).Before(nbf) {
+		return TokenNotYetValidError
+	}
+	if v.Audience != "" && !containsString(claims.Audience(), v.Audience) {
+		return InvalidAudienceError
+	}
+	if v.Issuer != "" && claims.Issuer() != v.Issuer {
+		return InvalidIssuerError
+	}
+	return nil
+}
+
+func verifySignature(alg string, key interface{}, signed string, signature []byte) error {
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return UnknownKeyError
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return InvalidSignatureError
+		}
+		return nil
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return UnknownKeyError
+		}
+		hash := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature) != nil {
+			return InvalidSignatureError
+		}
+		return nil
+	}
+	return UnsupportedAlgorithmError
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// BearerAuth authenticates requests by a JWT in the Authorization header. It is an IdentityProvider to be
+// used with policies (see Router.IdentityProvider), and an Injector providing the wrouter.Claims of the token
+// to actions.
+type BearerAuth struct {
+	// Realm is sent in the WWW-Authenticate header of 401 responses
+	Realm string
+	// Verifier verifies the tokens
+	Verifier *JWTVerifier
+}
+
+// NewBearerAuth creates a BearerAuth using the given verifier
+func NewBearerAuth(realm string, verifier *JWTVerifier) *BearerAuth {
+	return &BearerAuth{Realm: realm, Verifier: verifier}
+}
+
+// Claims returns the verified claims of the request's bearer token
+func (b *BearerAuth) Claims(ctx *InjectorContext) (Claims, error) {
+	auth := ctx.Request.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return nil, MalformedTokenError
+	}
+	return b.Verifier.Verify(strings.TrimSpace(auth[7:]))
+}
+
+// Identify implements the IdentityProvider interface
+func (b *BearerAuth) Identify(ctx *InjectorContext) Identity {
+	claims, err := b.Claims(ctx)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// Challenge implements the Challenger interface
+func (b *BearerAuth) Challenge() string {
+	return "Bearer realm=" + quoteParam(b.Realm)
+}
+
+// Supports implements the Injector interface
+func (b *BearerAuth) Supports(t string) bool {
+	return t == "wrouter.Claims"
+}
+
+// Get implements the Injector interface. It returns nil for requests without a valid token.
+func (b *BearerAuth) Get(ctx *InjectorContext) interface{} {
+	if claims, ok := IdentityFromContext(ctx.Request.Context()).(Claims); ok {
+		return claims
+	}
+	if claims, err := b.Claims(ctx); err == nil {
+		return claims
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package wrouter
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type tAccountController struct{}
+
+func (t *tAccountController) BasicAction(w http.ResponseWriter, id *BasicIdentity) {
+	if id != nil {
+		w.Write([]byte(id.User))
+	}
+}
+
+func (t *tAccountController) BearerAction(w http.ResponseWriter, claims Claims) {
+	if claims != nil {
+		w.Write([]byte(claims.Subject()))
+	}
+}
+
+func tSignToken(t *testing.T, alg, kid string, key interface{}, claims Claims) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		hash := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestBasicAuth(t *testing.T) {
+	auth := NewBasicAuth("admin area", StaticCredentials{"alice": "secret"})
+	rt := NewRouter()
+	rt.IdentityProvider = auth
+	rt.AddInjector(auth)
+	rt.AddController(&tAccountController{})
+	rt.Group("/private").Require(RequireAuthenticated()).HandleFunc("/", nil,
+		func(w http.ResponseWriter, r *http.Request) {})
+
+	cases := []struct {
+		uri, user, password string
+		status              int
+		body                string
+	}{
+		{"/taccount/basic", "alice", "secret", 200, "alice"},
+		{"/taccount/basic", "alice", "wrong", 200, ""},
+		{"/private", "", "", 401, "Unauthorized"},
+		{"/private", "bob", "secret", 401, "Unauthorized"},
+		{"/private", "alice", "secret", 200, ""},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.uri, nil)
+		if c.user != "" {
+			request.SetBasicAuth(c.user, c.password)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("%s as %s:%s: expected %d %q, got %d %q", c.uri, c.user, c.password, c.status, c.body,
+				rec.Code, rec.Body.String())
+		}
+		if rec.Code == 401 && rec.Header().Get("WWW-Authenticate") != `Basic realm="admin area", charset="UTF-8"` {
+			t.Errorf("Unexpected challenge %q", rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	secret := []byte("hmac-secret")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes())},
+		{"kty": "oct", "kid": "hs1", "k": base64.RawURLEncoding.EncodeToString(secret)},
+	}})
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &JWTVerifier{Keys: keys, Issuer: "issuer", Audience: "api", Leeway: time.Minute,
+		Now: func() time.Time { return now }}
+
+	valid := Claims{"sub": "alice", "iss": "issuer", "aud": []string{"api", "other"},
+		"exp": now.Add(time.Hour).Unix(), "roles": []string{"admin"}, "scope": "read write"}
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"rs256", tSignToken(t, "RS256", "rsa1", privateKey, valid), nil},
+		{"hs256", tSignToken(t, "HS256", "hs1", secret, valid), nil},
+		{"wrong key type", tSignToken(t, "HS256", "rsa1", secret, valid), UnknownKeyError},
+		{"unknown kid", tSignToken(t, "HS256", "hs2", secret, valid), UnknownKeyError},
+		{"bad signature", tSignToken(t, "HS256", "hs1", []byte("other"), valid), InvalidSignatureError},
+		{"none", tSignToken(t, "none", "", nil, valid), UnsupportedAlgorithmError},
+		{"malformed", "abc.def", MalformedTokenError},
+		{"expired", tSignToken(t, "HS256", "hs1", secret, Claims{"sub": "alice", "iss": "issuer", "aud": "api",
+			"exp": now.Add(-2 * time.Minute).Unix()}), TokenExpiredError},
+		{"leeway", tSignToken(t, "HS256", "hs1", secret, Claims{"sub": "alice", "iss": "issuer", "aud": "api",
+			"exp": now.Add(-30 * time.Second).Unix()}), nil},
+		{"not before", tSignToken(t, "HS256", "hs1", secret, Claims{"sub": "alice", "iss": "issuer", "aud": "api",
+			"nbf": now.Add(time.Hour).Unix()}), TokenNotYetValidError},
+		{"audience", tSignToken(t, "HS256", "hs1", secret, Claims{"sub": "alice", "iss": "issuer",
+			"aud": "web"}), InvalidAudienceError},
+		{"issuer", tSignToken(t, "HS256", "hs1", secret, Claims{"sub": "alice", "iss": "evil",
+			"aud": "api"}), InvalidIssuerError},
+	}
+	for _, c := range cases {
+		claims, err := verifier.Verify(c.token)
+		if err != c.err {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+		if err == nil && claims.Name() != "alice" {
+			t.Errorf("%s: unexpected subject %q", c.name, claims.Name())
+		}
+	}
+
+	// Empty symmetric keys would verify tokens signed by anyone
+	for _, empty := range []string{`{"kty":"oct","kid":"e"}`, `{"kty":"oct","kid":"e","k":""}`} {
+		if _, err := ParseJWKS([]byte(`{"keys":[` + empty + `]}`)); err == nil {
+			t.Errorf("Expected empty symmetric key %s to be rejected", empty)
+		}
+	}
+
+	claims, _ := verifier.Verify(cases[0].token)
+	if !claims.HasRole("admin") || claims.HasRole("user") || !claims.HasScope("write") || claims.HasScope("delete") {
+		t.Errorf("Unexpected roles or scopes in %v", claims)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	secret := []byte("hmac-secret")
+	auth := NewBearerAuth("api", &JWTVerifier{Keys: &StaticKeys{HMACSecret: secret}})
+	rt := NewRouter()
+	rt.IdentityProvider = auth
+	rt.AddInjector(auth)
+	rt.AddController(&tAccountController{})
+	rt.Group("/scoped").Require(RequireScopes("write")).HandleFunc("/", nil,
+		func(w http.ResponseWriter, r *http.Request) {})
+
+	reader := tSignToken(t, "HS256", "", secret, Claims{"sub": "bob", "scope": "read"})
+	writer := tSignToken(t, "HS256", "", secret, Claims{"sub": "carol", "scp": []string{"read", "write"}})
+	cases := []struct {
+		uri, token string
+		status     int
+		body       string
+	}{
+		{"/taccount/bearer", reader, 200, "bob"},
+		{"/taccount/bearer", "", 200, ""},
+		{"/scoped", "", 401, "Unauthorized"},
+		{"/scoped", reader + "x", 401, "Unauthorized"},
+		{"/scoped", reader, 403, "Forbidden"},
+		{"/scoped", writer, 200, ""},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.uri, nil)
+		if c.token != "" {
+			request.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("%s: expected %d %q, got %d %q", c.uri, c.status, c.body, rec.Code, rec.Body.String())
+		}
+		if rec.Code == 401 && rec.Header().Get("WWW-Authenticate") != `Bearer realm="api"` {
+			t.Errorf("Unexpected challenge %q", rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestMixedAuthInjection(t *testing.T) {
+	secret := []byte("hmac-secret")
+	bearer := NewBearerAuth("api", &JWTVerifier{Keys: &StaticKeys{HMACSecret: secret}})
+	rt := NewRouter()
+	rt.IdentityProvider = bearer
+	rt.AddInjector(bearer)
+	rt.AddInjector(NewBasicAuth("admin area", StaticCredentials{"alice": "secret"}))
+	rt.Group("/mixed").Require(RequireAuthenticated()).AddController(&tAccountController{})
+
+	// The claims resolved by the identity provider are not injected as *BasicIdentity
+	request := httptest.NewRequest("GET", "/mixed/taccount/basic", nil)
+	request.Header.Set("Authorization", "Bearer "+tSignToken(t, "HS256", "", secret, Claims{"sub": "bob"}))
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 200 || rec.Body.String() != "" {
+		t.Errorf("Expected no basic identity, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,228 @@
+package wrouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Identity represents the authenticated principal of a request
+type Identity interface {
+	// Name returns the name of the principal, e.g. a user name or client ID
+	Name() string
+	HasRole(role string) bool
+	HasScope(scope string) bool
+}
+
+// IdentityProvider resolves the Identity of a request, e.g. from a session, a token or an injector. It returns
+// nil for anonymous requests. See: Router.IdentityProvider
+type IdentityProvider interface {
+	Identify(*InjectorContext) Identity
+}
+
+// IdentityProviderFunc is an adapter to allow the use of ordinary functions as IdentityProvider
+type IdentityProviderFunc func(*InjectorContext) Identity
+
+// Identify implements the IdentityProvider interface
+func (f IdentityProviderFunc) Identify(ctx *InjectorContext) Identity {
+	return f(ctx)
+}
+
+// Challenger can be implemented by an IdentityProvider, to set the WWW-Authenticate header of 401 responses
+type Challenger interface {
+	Challenge() string
+}
+
+// InjectorIdentityProvider creates an IdentityProvider using the router's injectors for the given type, e.g.
+// "*myapp.User". The injected value must implement Identity.
+func InjectorIdentityProvider(typeName string) IdentityProvider {
+	return IdentityProviderFunc(func(ctx *InjectorContext) Identity {
+		id, _ := ctx.Router.inject(typeName, ctx).(Identity)
+		return id
+	})
+}
+
+// Policy decides whether a request may call a route. Policies are attached to routes by Route.Require,
+// Group.Require or the MetaPolicies metadata key, and evaluated after the route has been resolved, before the
+// action or handler is called.
+type Policy interface {
+	// Authorize returns true if the identity may call the route. The identity is nil for anonymous requests.
+	Authorize(id Identity, ctx *InjectorContext) bool
+	// String describes the policy in the exported route table
+	String() string
+}
+
+// Metadata keys evaluated by the authorization. MetaPolicies holds a []Policy. MetaRoles and MetaScopes hold
+// comma separated lists, so that they can be declared by struct tags: MetaRoles requires any of the roles,
+// MetaScopes all of the scopes. MetaAuthenticated requires an identity.
+const (
+	MetaPolicies      = "auth.policies"
+	MetaRoles         = "roles"
+	MetaScopes        = "scopes"
+	MetaAuthenticated = "authenticated"
+)
+
+type policy struct {
+	name string
+	fn   func(Identity, *InjectorContext) bool
+}
+
+func (p *policy) Authorize(id Identity, ctx *InjectorContext) bool { return p.fn(id, ctx) }
+func (p *policy) String() string                                   { return p.name }
+
+// PolicyFunc creates a Policy from a predicate. The name describes the policy in the exported route table.
+func PolicyFunc(name string, fn func(Identity, *InjectorContext) bool) Policy {
+	return &policy{name: name, fn: fn}
+}
+
+// RequireAuthenticated creates a Policy requiring an identity
+func RequireAuthenticated() Policy {
+	return PolicyFunc("authenticated", func(id Identity, _ *InjectorContext) bool {
+		return id != nil
+	})
+}
+
+// RequireRoles creates a Policy requiring all of the given roles
+func RequireRoles(roles ...string) Policy {
+	return PolicyFunc("roles("+strings.Join(roles, " & ")+")", func(id Identity, _ *InjectorContext) bool {
+		if id == nil {
+			return false
+		}
+		for _, role := range roles {
+			if !id.HasRole(role) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireAnyRole creates a Policy requiring at least one of the given roles
+func RequireAnyRole(roles ...string) Policy {
+	return PolicyFunc("roles("+strings.Join(roles, " | ")+")", func(id Identity, _ *InjectorContext) bool {
+		if id == nil {
+			return false
+		}
+		for _, role := range roles {
+			if id.HasRole(role) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequireScopes creates a Policy requiring all of the given scopes
+func RequireScopes(scopes ...string) Policy {
+	return PolicyFunc("scopes("+strings.Join(scopes, " & ")+")", func(id Identity, _ *InjectorContext) bool {
+		if id == nil {
+			return false
+		}
+		for _, scope := range scopes {
+			if !id.HasScope(scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Require attaches policies to the route. All policies must authorize a request.
+func (r *Route) Require(policies ...Policy) *Route {
+	existing, _ := r.Metadata[MetaPolicies].([]Policy)
+	return r.SetMeta(MetaPolicies, append(append([]Policy{}, existing...), policies...))
+}
+
+// Require returns a copy of the group, which attaches the policies to every route added through it, in
+// addition to the policies of the route itself.
+func (g *Group) Require(policies ...Policy) *Group {
+	existing, _ := g.metadata[MetaPolicies].([]Policy)
+	return g.WithMeta(MetaPolicies, append(append([]Policy{}, existing...), policies...))
+}
+
+// routePolicies returns all policies of a route, including those declared by MetaRoles, MetaScopes and
+// MetaAuthenticated
+func routePolicies(route *Route) []Policy {
+	if len(route.Metadata) == 0 {
+		return nil
+	}
+
+	// The policies are copied, the slice of the route is shared by concurrent requests
+	policies, _ := route.Metadata[MetaPolicies].([]Policy)
+	policies = append([]Policy(nil), policies...)
+	if route.Metadata.Bool(MetaAuthenticated) {
+		policies = append(policies, RequireAuthenticated())
+	}
+	if roles := splitList(route.Metadata.String(MetaRoles)); len(roles) != 0 {
+		policies = append(policies, RequireAnyRole(roles...))
+	}
+	if scopes := splitList(route.Metadata.String(MetaScopes)); len(scopes) != 0 {
+		policies = append(policies, RequireScopes(scopes...))
+	}
+	return policies
+}
+
+type identityContextKey struct{}
+
+// IdentityFromContext returns the identity of the request the context belongs to. It is only set for routes
+// with policies; otherwise the identity can be injected into actions by an argument of type wrouter.Identity.
+func IdentityFromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityContextKey{}).(Identity)
+	return id
+}
+
+// authorize evaluates the policies of the route. It returns the request carrying the identity, or nil if the
+// request has been answered with 401 (no identity) or 403 (identity not authorized).
+func (r *Router) authorize(w http.ResponseWriter, h *http.Request, route *Route) *http.Request {
+	policies := routePolicies(route)
+	if len(policies) == 0 {
+		return h
+	}
+
+	ctx := createInjectorContext(h, route, r, w)
+	var id Identity
+	if r.IdentityProvider != nil {
+		id = r.IdentityProvider.Identify(ctx)
+	}
+
+	for _, p := range policies {
+		if p.Authorize(id, ctx) {
+			continue
+		}
+		if id == nil {
+			if c, ok := r.IdentityProvider.(Challenger); ok {
+				w.Header().Set("WWW-Authenticate", c.Challenge())
+			}
+			r.serveError(w, h, http.StatusUnauthorized)
+		} else {
+			r.serveError(w, h, http.StatusForbidden)
+		}
+		return nil
+	}
+
+	if id == nil {
+		return h
+	}
+	return h.WithContext(context.WithValue(h.Context(), identityContextKey{}, id))
+}
+
+// identity returns the identity of the request, as resolved by the authorization or the IdentityProvider
+func (r *Router) identity(ctx *InjectorContext) Identity {
+	if id := IdentityFromContext(ctx.Request.Context()); id != nil {
+		return id
+	}
+	if r.IdentityProvider != nil {
+		return r.IdentityProvider.Identify(ctx)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,116 @@
+package wrouter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tIdentity struct {
+	name  string
+	roles []string
+}
+
+func (t *tIdentity) Name() string               { return t.name }
+func (t *tIdentity) HasScope(scope string) bool { return false }
+func (t *tIdentity) HasRole(role string) bool {
+	for _, r := range t.roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type tIdentityProvider struct{}
+
+func (t *tIdentityProvider) Identify(ctx *InjectorContext) Identity {
+	switch ctx.Request.Header.Get("X-User") {
+	case "":
+		return nil
+	case "root":
+		return &tIdentity{"root", []string{"admin"}}
+	}
+	return &tIdentity{ctx.Request.Header.Get("X-User"), []string{"user"}}
+}
+
+func (t *tIdentityProvider) Challenge() string { return "Custom realm=\"test\"" }
+
+type tSecureController struct {
+	_ Meta `action:"ProfileAction" authenticated:"true"`
+	_ Meta `action:"Delete_UserAction" roles:"admin, owner"`
+}
+
+func (t *tSecureController) ProfileAction(w http.ResponseWriter, id Identity) {
+	w.Write([]byte(id.Name()))
+}
+func (t *tSecureController) Delete_UserAction() {}
+func (t *tSecureController) PublicAction()      {}
+
+func TestAuthorization(t *testing.T) {
+	rt := NewRouter()
+	rt.IdentityProvider = new(tIdentityProvider)
+	rt.AddController(&tSecureController{})
+	rt.Group("/admin").Require(RequireRoles("admin")).HandleFunc("/stats", nil,
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(IdentityFromContext(r.Context()).Name())) }).
+		Require(PolicyFunc("weekday", func(id Identity, ctx *InjectorContext) bool { return true }))
+
+	cases := []struct {
+		method, uri, user string
+		status            int
+		body              string
+	}{
+		{"GET", "/tsecure/public", "", 200, ""},
+		{"GET", "/tsecure/profile", "", 401, "Unauthorized"},
+		{"GET", "/tsecure/profile", "bob", 200, "bob"},
+		{"DELETE", "/tsecure/user", "bob", 403, "Forbidden"},
+		{"DELETE", "/tsecure/user", "root", 200, ""},
+		{"GET", "/admin/stats", "bob", 403, "Forbidden"},
+		{"GET", "/admin/stats", "root", 200, "root"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.uri, nil)
+		if c.user != "" {
+			request.Header.Set("X-User", c.user)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("%s %s as %q: expected %d %q, got %d %q", c.method, c.uri, c.user, c.status, c.body,
+				rec.Code, rec.Body.String())
+		}
+		if c.status == 401 && rec.Header().Get("WWW-Authenticate") != "Custom realm=\"test\"" {
+			t.Errorf("Missing challenge on 401")
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	rt.ExportRoutes(buf)
+	if !strings.Contains(buf.String(), "\"roles(admin)\"") || !strings.Contains(buf.String(), "\"weekday\"") {
+		t.Errorf("Policies missing in route export: %s", buf.String())
+	}
+}
+
+func TestRoutePoliciesConcurrent(t *testing.T) {
+	policies := make([]Policy, 1, 4)
+	policies[0] = RequireRoles("admin")
+	route := new(Route).SetMeta(MetaPolicies, policies).SetMeta(MetaAuthenticated, true).SetMeta(MetaRoles, "x")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n := len(routePolicies(route)); n != 3 {
+				t.Errorf("Expected 3 policies, got %d", n)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(route.Metadata[MetaPolicies].([]Policy)) != 1 {
+		t.Error("Expected the policies of the route to be unchanged")
+	}
+}
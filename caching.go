@@ -0,0 +1,318 @@
+package wrouter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Metadata keys for the caching of action responses
+const (
+	// MetaETag enables weak ETags computed from the rendered body of the route's responses, and with it the
+	// handling of conditional requests. Its value is a bool or a string parsed by strconv.ParseBool.
+	MetaETag = "cache.etag"
+	// MetaCacheControl is the Cache-Control header set on the route's responses, e.g. "public, max-age=60"
+	MetaCacheControl = "cache.control"
+)
+
+// ETag can be returned by controller actions to set the ETag header of the response. Unquoted values are
+// quoted. It is applied before the PostRequest events render the response.
+type ETag string
+
+// LastModified can be returned by controller actions to set the Last-Modified header of the response. It is
+// applied before the PostRequest events render the response.
+type LastModified time.Time
+
+// Caching helps actions to set validators and caching headers, and to evaluate the preconditions of
+// conditional requests. It can be injected into controller actions by declaring an argument of type
+// *wrouter.Caching. Actions changing resources should set the validators of the current resource state and
+// call CheckPreconditions before changing it. Otherwise, If-Match and If-Unmodified-Since are enforced by the
+// router when the header of a successful response is written, which answers with 412 and discards the body.
+type Caching struct {
+	router *Router
+	w      http.ResponseWriter
+	h      *http.Request
+	writer *conditionalWriter
+}
+
+// SetETag sets a strong ETag. Unquoted values are quoted.
+func (c *Caching) SetETag(tag string) {
+	c.w.Header().Set("ETag", quoteETag(tag))
+}
+
+// SetWeakETag sets a weak ETag, for responses which are semantically, but not byte by byte equal
+func (c *Caching) SetWeakETag(tag string) {
+	c.w.Header().Set("ETag", "W/"+quoteETag(tag))
+}
+
+// SetLastModified sets the Last-Modified header
+func (c *Caching) SetLastModified(t time.Time) {
+	if !t.IsZero() {
+		c.w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// SetCacheControl sets the Cache-Control header, e.g. "private, max-age=0"
+func (c *Caching) SetCacheControl(value string) {
+	c.w.Header().Set("Cache-Control", value)
+}
+
+// ComputeETag requests a weak ETag computed from the rendered body, if the action sets no ETag itself. The
+// body is buffered until the response is complete.
+func (c *Caching) ComputeETag() {
+	if c.writer != nil {
+		c.writer.compute = true
+	}
+}
+
+// CheckPreconditions evaluates the conditional headers of the request against the validators set so far.
+// It returns false, if the request has been answered with 304 Not Modified or 412 Precondition Failed, and
+// the action should return without changing or rendering anything.
+func (c *Caching) CheckPreconditions() bool {
+	status := evaluatePreconditions(c.h, c.w.Header())
+	if status == 0 {
+		return true
+	}
+	if c.writer != nil {
+		c.writer.answer(status)
+	} else {
+		c.w.WriteHeader(status)
+	}
+	return false
+}
+
+// newCaching creates the Caching helper of an action call
+func newCaching(ctx *InjectorContext) *Caching {
+	writer, _ := ctx.ResponseWriter.(*conditionalWriter)
+	return &Caching{router: ctx.Router, w: ctx.ResponseWriter, h: ctx.Request, writer: writer}
+}
+
+// Tag attaches tags to the response, if it is stored in the response cache, e.g. "article:42". See:
+// CachePolicy
+func (c *Caching) Tag(tags ...string) {
+	AddCacheTags(c.h.Context(), tags...)
+}
+
+// Invalidate removes all cached responses carrying one of the tags, e.g. after changing the tagged resource
+func (c *Caching) Invalidate(tags ...string) {
+	c.router.InvalidateCache(tags...)
+}
+
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, "\"") || strings.HasPrefix(tag, "W/\"") {
+		return tag
+	}
+	return "\"" + tag + "\""
+}
+
+// evaluatePreconditions evaluates the conditional headers in the order of RFC 9110, section 13.2.2, and returns
+// the status to answer with, or 0 if the request may proceed
+func evaluatePreconditions(h *http.Request, header http.Header) int {
+	etag := header.Get("ETag")
+	lastModified, lmErr := http.ParseTime(header.Get("Last-Modified"))
+	safe := h.Method == "GET" || h.Method == "HEAD"
+
+	if ifMatch := h.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(h.Header.Get("If-Unmodified-Since")); err == nil && lmErr == nil {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := h.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(h.Header.Get("If-Modified-Since")); err == nil && lmErr == nil && safe {
+		if !lastModified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag returns true, if etag is contained in the list of a conditional header. Weak comparison ignores
+// the weakness indicator, strong comparison never matches weak ETags.
+func matchETag(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range splitList(list) {
+		if !weak && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalWriter answers conditional GET and HEAD requests with 304, and requests failing their
+// preconditions with 412 when the header is written. It computes weak ETags from buffered bodies if requested.
+type conditionalWriter struct {
+	http.ResponseWriter
+	h       *http.Request
+	compute bool
+
+	status     int
+	buf        bytes.Buffer
+	buffering  bool
+	suppressed bool
+}
+
+func newConditionalWriter(w http.ResponseWriter, h *http.Request, compute bool) *conditionalWriter {
+	return &conditionalWriter{ResponseWriter: w, h: h, compute: compute}
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (c *conditionalWriter) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = status
+	if c.h.Method != "GET" && c.h.Method != "HEAD" {
+		// Unsafe methods are checked against the validators set by the action, e.g. a stale If-Match
+		if status >= 200 && status < 300 &&
+			evaluatePreconditions(c.h, c.Header()) == http.StatusPreconditionFailed {
+			c.answer(http.StatusPreconditionFailed)
+			return
+		}
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if status != http.StatusOK {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if c.compute && c.Header().Get("ETag") == "" {
+		c.buffering = true
+		return
+	}
+	c.writeHeader()
+}
+
+// writeHeader writes the header of a 200 response, or 304 if the validators match the request, or 412 if
+// they fail its preconditions
+func (c *conditionalWriter) writeHeader() {
+	if status := evaluatePreconditions(c.h, c.Header()); status != 0 {
+		c.answer(status)
+		return
+	}
+	c.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+// answer writes the header of a 304 or 412 response and discards the body
+func (c *conditionalWriter) answer(status int) {
+	c.status = status
+	c.suppressed = true
+	header := c.Header()
+	for _, key := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+		header.Del(key)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface
+func (c *conditionalWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.suppressed {
+		return len(b), nil
+	}
+	if c.buffering {
+		return c.buf.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Written returns true, if the response has been started
+func (c *conditionalWriter) Written() bool {
+	return c.status != 0
+}
+
+// Flush implements the http.Flusher interface. Flushing ends the buffering of the body, the response then has
+// no computed ETag.
+func (c *conditionalWriter) Flush() {
+	if c.buffering {
+		c.buffering = false
+		c.ResponseWriter.WriteHeader(http.StatusOK)
+		c.ResponseWriter.Write(c.buf.Bytes())
+		c.buf.Reset()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. It is used by http.ResponseController.
+func (c *conditionalWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// finish computes the ETag of a buffered body and writes the response. Responses without body are checked,
+// if the action has set validators.
+func (c *conditionalWriter) finish() {
+	if c.status == 0 && (c.Header().Get("ETag") != "" || c.Header().Get("Last-Modified") != "") {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.buffering {
+		return
+	}
+	c.buffering = false
+	sum := sha256.Sum256(c.buf.Bytes())
+	c.Header().Set("ETag", "W/\""+hex.EncodeToString(sum[:12])+"\"")
+	c.writeHeader()
+	if !c.suppressed {
+		c.ResponseWriter.Write(c.buf.Bytes())
+	}
+}
+
+var cachingType = reflect.TypeOf((*Caching)(nil))
+
+// conditionalResponse wraps the ResponseWriter of an action call, if caching is enabled, requested by the
+// route metadata, or the action declares a *Caching argument. It returns nil otherwise.
+func (r *Router) conditionalResponse(w http.ResponseWriter, h *http.Request, route *Route) *conditionalWriter {
+	compute := r.Configuration.Caching.WeakETags || route.Metadata.Bool(MetaETag)
+	enabled := r.Configuration.Caching.Enabled || compute
+	for i := 1; !enabled && i < route.RMethod.Type.NumIn(); i++ {
+		enabled = route.RMethod.Type.In(i) == cachingType
+	}
+	if !enabled {
+		return nil
+	}
+	return newConditionalWriter(w, h, compute)
+}
+
+// applyValidators sets the ETag and Last-Modified values returned by an action on the response
+func applyValidators(w http.ResponseWriter, values []reflect.Value) {
+	for _, v := range values {
+		switch v := v.Interface().(type) {
+		case ETag:
+			if v != "" {
+				w.Header().Set("ETag", quoteETag(string(v)))
+			}
+		case LastModified:
+			if t := time.Time(v); !t.IsZero() {
+				w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var tModified = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type tDocumentController struct {
+	_ Meta `action:"ListAction" cache.etag:"true" cache.control:"max-age=60"`
+}
+
+func (t *tDocumentController) ShowAction(w http.ResponseWriter, c *Caching) {
+	c.SetETag("v1")
+	c.SetLastModified(tModified)
+	w.Write([]byte("document"))
+}
+
+func (t *tDocumentController) Put_ShowAction(w http.ResponseWriter, c *Caching) {
+	c.SetETag("v1")
+	if !c.CheckPreconditions() {
+		return
+	}
+	w.Write([]byte("updated"))
+}
+
+func (t *tDocumentController) ListAction(w http.ResponseWriter) {
+	w.Write([]byte("list"))
+}
+
+func (t *tDocumentController) VersionAction() (ETag, LastModified) {
+	return "v2", LastModified(tModified)
+}
+
+// Put_VersionAction relies on the router to enforce the preconditions
+func (t *tDocumentController) Put_VersionAction() ETag {
+	return "v2"
+}
+
+func TestConditionalRequests(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tDocumentController{})
+	rt.AppendPostRequestEvent(&tRenderEvent{})
+	rt.Configuration.Caching.Enabled = true
+
+	listETag := func() string {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", "/tdocument/list", nil))
+		if rec.Header().Get("Cache-Control") != "max-age=60" {
+			t.Errorf("Missing Cache-Control from metadata")
+		}
+		return rec.Header().Get("ETag")
+	}()
+	if len(listETag) < 4 || listETag[:3] != "W/\"" {
+		t.Fatalf("Expected computed weak ETag, got %q", listETag)
+	}
+
+	cases := []struct {
+		method, uri, header, value string
+		status                     int
+		body                       string
+	}{
+		{"GET", "/tdocument/show", "", "", 200, "document"},
+		{"GET", "/tdocument/show", "If-None-Match", `"v0", "v1"`, 304, ""},
+		{"GET", "/tdocument/show", "If-None-Match", `W/"v1"`, 304, ""},
+		{"GET", "/tdocument/show", "If-None-Match", "*", 304, ""},
+		{"GET", "/tdocument/show", "If-None-Match", `"v0"`, 200, "document"},
+		{"GET", "/tdocument/show", "If-Modified-Since", tModified.Format(http.TimeFormat), 304, ""},
+		{"GET", "/tdocument/show", "If-Modified-Since", tModified.Add(-time.Hour).Format(http.TimeFormat), 200,
+			"document"},
+		{"PUT", "/tdocument/show", "If-Match", `"v1"`, 200, "updated"},
+		{"PUT", "/tdocument/show", "If-Match", `"v0"`, 412, ""},
+		{"PUT", "/tdocument/show", "If-Match", `W/"v1"`, 412, ""},
+		{"PUT", "/tdocument/show", "If-None-Match", "*", 412, ""},
+		{"GET", "/tdocument/list", "If-None-Match", listETag, 304, ""},
+		{"GET", "/tdocument/version", "If-None-Match", `"v2"`, 304, ""},
+		{"GET", "/tdocument/version", "If-None-Match", `"v1"`, 200, "v2 " + tModified.String()},
+		{"PUT", "/tdocument/version", "If-Match", `"v1"`, 412, ""},
+		{"PUT", "/tdocument/version", "If-Match", `"v2"`, 200, "v2"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.uri, nil)
+		if c.header != "" {
+			request.Header.Set(c.header, c.value)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("%s %s with %s %s: expected %d %q, got %d %q", c.method, c.uri, c.header, c.value, c.status,
+				c.body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// tRenderEvent renders the returned values separated by spaces
+type tRenderEvent struct{}
+
+func (t *tRenderEvent) Exec(ctx *PostRequestEventContext) {
+	for i, v := range ctx.Values {
+		if i > 0 {
+			ctx.ResponseWriter.Write([]byte(" "))
+		}
+		if v.Type() == reflect.TypeOf(LastModified{}) {
+			ctx.ResponseWriter.Write([]byte(time.Time(v.Interface().(LastModified)).String()))
+			continue
+		}
+		ctx.ResponseWriter.Write([]byte(v.String()))
+	}
+}
@@ -0,0 +1,117 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TrailingSlashPolicy defines how requests with a trailing slash are handled. The canonical form of every
+// route, including the alias routes created by CreateAliasRoutes, is the path without trailing slash.
+// See Configuration.TrailingSlash
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashLenient serves /user/ and /user alike
+	TrailingSlashLenient TrailingSlashPolicy = iota
+	// TrailingSlashStrict answers requests with a trailing slash with 404
+	TrailingSlashStrict
+	// TrailingSlashRedirect redirects requests with a trailing slash to the canonical path. GET and HEAD
+	// requests are redirected with 301, all others with 308. The query string is preserved.
+	TrailingSlashRedirect
+)
+
+// cleanPath removes dot segments and duplicate slashes from an escaped path. A trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// canonicalize applies the path cleaning of the configuration. It returns the request to continue with, or
+// nil if the request has been answered with a redirect.
+func (r *Router) canonicalize(w http.ResponseWriter, h *http.Request) *http.Request {
+	if !r.Configuration.CleanPath {
+		return h
+	}
+
+	escaped := h.URL.EscapedPath()
+	cleaned := cleanPath(escaped)
+	if cleaned == escaped {
+		return h
+	}
+
+	if r.Configuration.RedirectCleanPath {
+		r.redirectPath(w, h, cleaned)
+		return nil
+	}
+	return withEscapedPath(h, cleaned)
+}
+
+// redirectPath redirects the request to the given escaped path, preserving the query string. GET and HEAD
+// requests are redirected with 301, all others with 308 so that the method and body are kept.
+func (r *Router) redirectPath(w http.ResponseWriter, h *http.Request, escaped string) {
+	// Leading slashes are collapsed, "//host" would redirect to another host
+	target := "/" + strings.TrimLeft(escaped, "/")
+	if h.URL.RawQuery != "" {
+		target += "?" + h.URL.RawQuery
+	}
+
+	status := http.StatusPermanentRedirect
+	if h.Method == "GET" || h.Method == "HEAD" {
+		status = http.StatusMovedPermanently
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(status)
+}
+
+// withEscapedPath returns a shallow copy of the request with the given escaped path
+func withEscapedPath(h *http.Request, escaped string) *http.Request {
+	p, err := url.PathUnescape(escaped)
+	if err != nil {
+		return h
+	}
+
+	h2 := new(http.Request)
+	*h2 = *h
+	h2.URL = new(url.URL)
+	*h2.URL = *h.URL
+	h2.URL.Path = p
+	h2.URL.RawPath = ""
+	if h2.URL.EscapedPath() != escaped {
+		h2.URL.RawPath = escaped
+	}
+	return h2
+}
+
+// applyTrailingSlash applies the TrailingSlashPolicy to a resolved request. It returns the route to continue
+// with, which is nil for requests answered by 404, and false if the request has been answered with a redirect.
+// Paths below the prefix of a mounted handler are left to the handler.
+func (r *Router) applyTrailingSlash(w http.ResponseWriter, h *http.Request, route *Route) (*Route, bool) {
+	policy := r.Configuration.TrailingSlash
+	escaped := h.URL.EscapedPath()
+	if policy == TrailingSlashLenient || route == nil || escaped == "/" || !strings.HasSuffix(escaped, "/") {
+		return route, true
+	}
+
+	trimmed := strings.TrimRight(escaped, "/")
+	if route.Prefix && !equalPath(route.Path, strings.Trim(trimmed, "/"), r.Configuration.CaseSensitiveRouting) {
+		return route, true
+	}
+
+	if policy == TrailingSlashStrict {
+		return nil, true
+	}
+
+	if trimmed == "" {
+		trimmed = "/"
+	}
+	r.redirectPath(w, h, trimmed)
+	return route, false
+}
@@ -0,0 +1,329 @@
+package wrouter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MetaCompress is the metadata key to enable or disable response compression per route, overriding
+// Configuration.Compression.Enabled. Its value is a bool or a string parsed by strconv.ParseBool.
+const MetaCompress = "compress"
+
+// Encoder compresses responses with one content coding. Encoders for codings not supported by the standard
+// library, e.g. brotli ("br"), can be added to Configuration.Compression.Encoders.
+type Encoder interface {
+	// Encoding returns the content coding as used in the Accept-Encoding and Content-Encoding headers
+	Encoding() string
+	// NewWriter returns a writer compressing into w. Flush is called on writers implementing http.Flusher or
+	// providing a Flush() error method when the response is flushed.
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoder compresses responses with gzip
+type GzipEncoder struct {
+	// Level is the compression level, see compress/gzip
+	Level int
+}
+
+// Encoding implements the Encoder interface
+func (e GzipEncoder) Encoding() string { return "gzip" }
+
+// NewWriter implements the Encoder interface
+func (e GzipEncoder) NewWriter(w io.Writer) io.WriteCloser {
+	gw, err := gzip.NewWriterLevel(w, e.Level)
+	if err != nil {
+		gw = gzip.NewWriter(w)
+	}
+	return gw
+}
+
+// DeflateEncoder compresses responses with deflate (zlib-less, as sent by most servers)
+type DeflateEncoder struct {
+	// Level is the compression level, see compress/flate
+	Level int
+}
+
+// Encoding implements the Encoder interface
+func (e DeflateEncoder) Encoding() string { return "deflate" }
+
+// NewWriter implements the Encoder interface
+func (e DeflateEncoder) NewWriter(w io.Writer) io.WriteCloser {
+	fw, err := flate.NewWriter(w, e.Level)
+	if err != nil {
+		fw, _ = flate.NewWriter(w, flate.DefaultCompression)
+	}
+	return fw
+}
+
+// DefaultEncoders are the encoders used if Configuration.Compression.Encoders is empty, in order of preference
+var DefaultEncoders = []Encoder{GzipEncoder{Level: gzip.DefaultCompression}, DeflateEncoder{Level: flate.DefaultCompression}}
+
+// DefaultSkipContentTypes contains the content types which are not compressed by default, because they are
+// compressed already, or because compressed event streams are buffered by many proxies and browsers. Entries
+// ending in "/" match all subtypes.
+var DefaultSkipContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff", "font/woff2", "application/zip", "application/gzip", "application/x-gzip",
+	"application/zstd", "application/pdf", "text/event-stream"}
+
+// Compress enables or disables the compression of the route's responses. It returns the route to allow
+// chaining.
+func (r *Route) Compress(enabled bool) *Route {
+	return r.SetMeta(MetaCompress, enabled)
+}
+
+// Compress returns a copy of the group, which enables or disables compression on every route added through
+// it, unless the route has its own setting
+func (g *Group) Compress(enabled bool) *Group {
+	return g.WithMeta(MetaCompress, enabled)
+}
+
+// compressResponse wraps the ResponseWriter to compress the response, if compression is enabled for the route
+// and the client accepts one of the configured encodings. The returned writer must be closed.
+func (r *Router) compressResponse(w http.ResponseWriter, h *http.Request, route *Route) (http.ResponseWriter, *compressWriter) {
+	cfg := &r.Configuration.Compression
+	enabled := cfg.Enabled
+	if _, ok := route.Metadata[MetaCompress]; ok {
+		enabled = route.Metadata.Bool(MetaCompress)
+	}
+	if !enabled {
+		return w, nil
+	}
+
+	// The response depends on Accept-Encoding, even if it is not compressed for this client
+	w.Header().Add("Vary", "Accept-Encoding")
+	if h.Method == "HEAD" {
+		return w, nil
+	}
+
+	encoders := cfg.Encoders
+	if len(encoders) == 0 {
+		encoders = DefaultEncoders
+	}
+	encoder := negotiateEncoding(h.Header.Get("Accept-Encoding"), encoders)
+	if encoder == nil {
+		return w, nil
+	}
+
+	skip := cfg.SkipContentTypes
+	if skip == nil {
+		skip = DefaultSkipContentTypes
+	}
+	cw := &compressWriter{ResponseWriter: w, encoder: encoder, minSize: cfg.MinSize, skip: skip}
+	return cw, cw
+}
+
+// negotiateEncoding returns the encoder with the highest quality in the Accept-Encoding header. On equal
+// quality, the order of the encoders decides. It returns nil, if no encoder is acceptable.
+func negotiateEncoding(accept string, encoders []Encoder) Encoder {
+	qualities := parseAcceptEncoding(accept)
+
+	var best Encoder
+	bestQ := 0.0
+	for _, encoder := range encoders {
+		if q := encodingQuality(qualities, encoder.Encoding()); q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+	return best
+}
+
+// parseAcceptEncoding returns the qualities of the content codings in an Accept-Encoding header, keyed by
+// the lower case coding
+func parseAcceptEncoding(accept string) map[string]float64 {
+	qualities := make(map[string]float64)
+	if accept == "" {
+		return qualities
+	}
+	for _, part := range splitList(accept) {
+		coding, q := parseQuality(part)
+		qualities[strings.ToLower(coding)] = q
+	}
+	return qualities
+}
+
+// encodingQuality returns the quality of the given content coding. A coding that is not listed takes the
+// quality of "*", or zero if there is no "*" either.
+func encodingQuality(qualities map[string]float64, coding string) float64 {
+	if q, ok := qualities[strings.ToLower(coding)]; ok {
+		return q
+	}
+	return qualities["*"]
+}
+
+// parseQuality splits an element of an Accept header into its value and quality
+func parseQuality(s string) (string, float64) {
+	value, params, _ := strings.Cut(s, ";")
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		key, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(key, "q") {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return strings.TrimSpace(value), q
+}
+
+// compressWriter compresses the response body. The decision whether to compress is deferred until MinSize
+// bytes have been written, the response is flushed or closed, so that small bodies are sent uncompressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+	minSize int
+	skip    []string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	writer  io.WriteCloser
+}
+
+// WriteHeader implements the http.ResponseWriter interface. The header is sent once the compression has
+// been decided.
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status != 0 || c.decided {
+		return
+	}
+	// Informational responses are sent immediately
+	if status >= 100 && status < 200 {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	c.status = status
+}
+
+// Write implements the http.ResponseWriter interface
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if !c.decided {
+		c.buf.Write(b)
+		if c.buf.Len() < c.minSize {
+			return len(b), nil
+		}
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if c.writer != nil {
+		return c.writer.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Written returns true, if the response has been started
+func (c *compressWriter) Written() bool {
+	return c.status != 0
+}
+
+// decide sends the header and the buffered body, compressed if compress is true and the response is eligible
+func (c *compressWriter) decide(compress bool) error {
+	c.decided = true
+	header := c.Header()
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if header.Get("Content-Type") == "" && c.buf.Len() != 0 {
+		// Sniffing the compressed body would detect the wrong type
+		header.Set("Content-Type", http.DetectContentType(c.buf.Bytes()))
+	}
+
+	if compress && c.eligible() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoder.Encoding())
+		// The compressed representation differs byte by byte
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		c.writer = c.encoder.NewWriter(c.ResponseWriter)
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if c.writer != nil {
+		_, err = c.writer.Write(c.buf.Bytes())
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf.Bytes())
+	}
+	c.buf.Reset()
+	return err
+}
+
+// eligible returns true, if the response may be compressed
+func (c *compressWriter) eligible() bool {
+	if c.status < 200 || c.status == http.StatusNoContent || c.status == http.StatusNotModified ||
+		c.status == http.StatusPartialContent {
+		return false
+	}
+	header := c.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType, _, _ := strings.Cut(strings.ToLower(header.Get("Content-Type")), ";")
+	contentType = strings.TrimSpace(contentType)
+	for _, skip := range c.skip {
+		if contentType == skip || strings.HasSuffix(skip, "/") && strings.HasPrefix(contentType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush implements the http.Flusher interface. Streamed responses are compressed regardless of their size.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		c.decide(true)
+	}
+	if c.writer != nil {
+		switch f := c.writer.(type) {
+		case interface{ Flush() error }:
+			f.Flush()
+		case http.Flusher:
+			f.Flush()
+		}
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, if the wrapped writer supports it
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := c.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("wrouter: the underlying ResponseWriter does not support hijacking")
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. It is used by http.ResponseController.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// close finishes the response. Bodies smaller than the minimum size are sent uncompressed.
+func (c *compressWriter) close() {
+	if !c.decided {
+		if c.status == 0 && c.buf.Len() == 0 {
+			// Nothing has been written, the response is completed by net/http
+			return
+		}
+		c.decide(false)
+	}
+	if c.writer != nil {
+		c.writer.Close()
+	}
+}
@@ -0,0 +1,84 @@
+package wrouter
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var tLargeBody = strings.Repeat("compressible text ", 200)
+
+func TestCompression(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.Compression.Enabled = true
+	rt.HandleFunc("/large", []string{"GET", "HEAD"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, tLargeBody)
+	})
+	rt.HandleFunc("/small", nil, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "small") })
+	rt.HandleFunc("/image", nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, tLargeBody)
+	})
+	rt.HandleFunc("/stream", nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+	})
+	rt.HandleFunc("/chunks", nil, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "chunk")
+		w.(http.Flusher).Flush()
+	})
+	rt.HandleFunc("/raw", nil, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, tLargeBody) }).
+		Compress(false)
+
+	cases := []struct {
+		method, uri, accept string
+		encoding            string
+		body                string
+	}{
+		{"GET", "/large", "gzip, deflate", "gzip", tLargeBody},
+		{"GET", "/large", "gzip;q=0.5, deflate", "deflate", tLargeBody},
+		{"GET", "/large", "br", "", tLargeBody},
+		{"GET", "/large", "gzip;q=0", "", tLargeBody},
+		{"GET", "/large", "*", "gzip", tLargeBody},
+		{"HEAD", "/large", "gzip", "", tLargeBody},
+		{"GET", "/small", "gzip", "", "small"},
+		{"GET", "/image", "gzip", "", tLargeBody},
+		{"GET", "/stream", "gzip", "", "data: 1\n\n"},
+		{"GET", "/chunks", "gzip", "gzip", "chunk"},
+		{"GET", "/raw", "gzip", "", tLargeBody},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.uri, nil)
+		request.Header.Set("Accept-Encoding", c.accept)
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+
+		var body io.Reader = rec.Body
+		switch rec.Header().Get("Content-Encoding") {
+		case "gzip":
+			var err error
+			if body, err = gzip.NewReader(rec.Body); err != nil {
+				t.Fatal(err)
+			}
+		case "deflate":
+			body = flate.NewReader(rec.Body)
+		}
+		b, _ := io.ReadAll(body)
+		if rec.Header().Get("Content-Encoding") != c.encoding || string(b) != c.body {
+			t.Errorf("%s %s with %q: expected encoding %q, got %q with %d bytes", c.method, c.uri, c.accept,
+				c.encoding, rec.Header().Get("Content-Encoding"), len(b))
+		}
+		if c.uri != "/raw" && rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s %s: missing Vary header", c.method, c.uri)
+		}
+		if c.uri == "/large" && c.encoding != "" && rec.Header().Get("ETag") != `W/"v1"` {
+			t.Errorf("Expected weak ETag for compressed response, got %q", rec.Header().Get("ETag"))
+		}
+	}
+}
@@ -2,7 +2,9 @@ package wrouter
 
 import (
 	"io"
+	"net/http"
 	"os"
+	"time"
 )
 
 // Configuration contains the configurable settings of the router
@@ -30,6 +32,41 @@ type Configuration struct {
 	// Default: true
 	AllowSubController bool
 
+	// ControllerLifetime defines how the instances of controllers added by Router.AddController are shared
+	// between requests: all requests share the registered instance (LifetimeSingleton), or every request gets a
+	// shallow copy of it (LifetimePrototype). The setting applies to controllers added after changing it. For
+	// controllers created by a factory per request, see Router.AddControllerFactory.
+	//
+	// Default: LifetimeSingleton
+	ControllerLifetime ControllerLifetime
+
+	// CaseSensitiveRouting when set to true, will match request paths against route paths case sensitively.
+	// Routes created from controllers by convention have lower case paths. Regardless of this setting, the
+	// request path is never lower-cased, so that mounted handlers and actions receive it as sent.
+	//
+	// Default: false
+	CaseSensitiveRouting bool
+
+	// CleanPath when set to true, will remove dot segments ("." and "..") and duplicate slashes from request
+	// paths before resolving the route.
+	//
+	// Default: true
+	CleanPath bool
+
+	// RedirectCleanPath when set to true and CleanPath is enabled, will redirect requests to the cleaned path
+	// instead of serving them under the uncleaned one. GET and HEAD requests are redirected with 301, all
+	// others with 308.
+	//
+	// Default: false
+	RedirectCleanPath bool
+
+	// TrailingSlash defines how requests to a route path with a trailing slash are handled: served like the
+	// path without it (TrailingSlashLenient), answered with 404 (TrailingSlashStrict) or redirected to the
+	// path without it (TrailingSlashRedirect).
+	//
+	// Default: TrailingSlashLenient
+	TrailingSlash TrailingSlashPolicy
+
 	// Verbosity contains all information about the verbosity of the Router. By default, Verbose settings are off.
 	// Enabling verbosity, depending on your verbosity settings, may have measurable performance drawbacks.
 	Verbosity struct {
@@ -51,6 +88,202 @@ type Configuration struct {
 		// Default: os.Stdout
 		Writer io.Writer
 	}
+
+	// AccessLog contains the settings of the built-in access log. By default, access logging is off.
+	AccessLog struct {
+		// Enabled when set to true, will make the router write one line per served request to the configured
+		// io.Writer, containing the status code, the amount of written bytes, the duration as well as the matched
+		// route path and controller action.
+		//
+		// Default: false
+		Enabled bool
+
+		// Format is the format of the written lines. See AccessLogCommon, AccessLogCombined and AccessLogJSON.
+		//
+		// Default: AccessLogCommon
+		Format AccessLogFormat
+
+		// Writer is the writer to which the access log is written.
+		//
+		// Default: os.Stdout
+		Writer io.Writer
+	}
+
+	// Metrics contains the settings of the built-in request metrics. By default, metrics are off.
+	Metrics struct {
+		// Enabled when set to true, will make the router collect request counts, error counts and latency
+		// histograms per route and serve them in the Prometheus text exposition format under Path.
+		//
+		// Default: false
+		Enabled bool
+
+		// Path is the path under which the metrics are served.
+		//
+		// Default: /metrics
+		Path string
+
+		// Buckets contains the upper bounds of the latency histogram in seconds. Changes have no effect after
+		// the first request has been observed.
+		//
+		// Default: DefaultMetricsBuckets
+		Buckets []float64
+	}
+
+	// RequestID contains the settings for request identifiers. By default, request IDs are off.
+	RequestID struct {
+		// Enabled when set to true, will make the router take the request ID from the incoming Header, or
+		// generate a new one if the header is missing or invalid. The ID is set on the response, stored in the
+		// request context, passed to all event and injector contexts and included in the access log.
+		//
+		// Default: false
+		Enabled bool
+
+		// Header is the name of the header carrying the request ID, both for the request and the response.
+		//
+		// Default: X-Request-ID
+		Header string
+
+		// Generator generates new request IDs. If nil, 16 random bytes in hex representation are used.
+		//
+		// Default: nil
+		Generator func() string
+	}
+
+	// CSRF contains the settings of the cross-site request forgery protection. By default, it is off.
+	CSRF struct {
+		// Enabled when set to true, will make the router reject requests with methods other than GET, HEAD,
+		// OPTIONS and TRACE with 403, unless they carry the CSRF token of the client in Header or FormField.
+		// Routes are exempted by the metadata MetaCSRFExempt. See: CSRFToken
+		//
+		// Default: false
+		Enabled bool
+
+		// Mode defines where the token is kept: in the session (CSRFSession) or in a cookie (CSRFDoubleSubmit).
+		//
+		// Default: CSRFSession
+		Mode CSRFMode
+
+		// Header is the name of the request header carrying the token, e.g. for requests sent by scripts.
+		//
+		// Default: X-CSRF-Token
+		Header string
+
+		// FormField is the name of the form field carrying the token.
+		//
+		// Default: csrf_token
+		FormField string
+
+		// CookieName is the name of the cookie carrying the token in CSRFDoubleSubmit mode.
+		//
+		// Default: csrf_token
+		CookieName string
+	}
+
+	// CORS is the CORS policy of all routes without a policy of their own. If nil, cross-origin requests are
+	// only allowed to routes with a policy. See: CORSPolicy, MetaCORS
+	//
+	// Default: nil
+	CORS *CORSPolicy
+
+	// Limits contains the limits of requests to routes. They can be overridden per route by the metadata
+	// MetaMaxBodySize, MetaTimeout and MetaReadTimeout. By default, requests are not limited.
+	Limits struct {
+		// MaxBodySize is the maximum size of request bodies in bytes. Requests announcing a larger body, and
+		// requests whose body turns out to be larger while the action reads it, are answered with 413.
+		// Zero disables the limit.
+		//
+		// Default: 0
+		MaxBodySize int64
+
+		// Timeout is the maximum duration of serving a route. When exceeded, the request context is cancelled
+		// and TimeoutStatus is served. Zero disables the timeout. Actions streaming events have no timeout.
+		//
+		// Default: 0
+		Timeout time.Duration
+
+		// TimeoutStatus is the status served on timeouts, e.g. 504 for routes proxying other services.
+		//
+		// Default: 503
+		TimeoutStatus int
+
+		// ReadTimeout is the maximum duration of reading the request body, counted from the resolving of the
+		// route. It requires a ResponseWriter supporting http.ResponseController. Zero disables the timeout.
+		//
+		// Default: 0
+		ReadTimeout time.Duration
+	}
+
+	// Compression contains the settings of response compression. It can be enabled or disabled per route by
+	// the metadata MetaCompress. By default, compression is off.
+	Compression struct {
+		// Enabled when set to true, will make the router compress responses with the best encoding accepted
+		// by the client. Responses of HEAD requests, without body and of the SkipContentTypes are not
+		// compressed.
+		//
+		// Default: false
+		Enabled bool
+
+		// MinSize is the minimum body size in bytes for a response to be compressed. Flushed responses are
+		// compressed regardless of their size.
+		//
+		// Default: 1024
+		MinSize int
+
+		// Encoders contains the available encoders, in order of preference.
+		//
+		// Default: DefaultEncoders
+		Encoders []Encoder
+
+		// SkipContentTypes contains the content types which are not compressed. Entries ending in "/" match
+		// all subtypes.
+		//
+		// Default: DefaultSkipContentTypes
+		SkipContentTypes []string
+	}
+
+	// Caching contains the settings for conditional requests to controller actions. Actions declaring an
+	// argument of type *wrouter.Caching and routes with the metadata MetaETag are always handled. By default,
+	// caching is off.
+	Caching struct {
+		// Enabled when set to true, will make the router answer conditional GET and HEAD requests with 304, if
+		// the ETag or Last-Modified header of the response matches If-None-Match or If-Modified-Since.
+		//
+		// Default: false
+		Enabled bool
+
+		// WeakETags when set to true, will make the router compute weak ETags from the body of all action
+		// responses without ETag. The bodies are buffered until the response is complete.
+		//
+		// Default: false
+		WeakETags bool
+	}
+
+	// Rendering contains the settings of the content negotiation for values returned by controller actions.
+	// See: Router.AddRenderer
+	Rendering struct {
+		// FormatParam is the name of the query parameter selecting a format by name, overriding the Accept
+		// header, e.g. "?format=csv". An empty name disables the parameter.
+		//
+		// Default: format
+		FormatParam string
+
+		// PathExtensions when set to true, will make the router resolve requests to paths ending in the
+		// extension of a registered format, e.g. "/article/list.json", to the route without the extension,
+		// and render the returned values in that format. Routes matching the path with the extension take
+		// precedence.
+		//
+		// Default: false
+		PathExtensions bool
+	}
+
+	// EventStream contains the settings of Server-Sent Events. See: EventStream
+	EventStream struct {
+		// Heartbeat is the interval of the comments sent to keep idle streams open, e.g. through proxies
+		// closing idle connections. Zero disables the heartbeat.
+		//
+		// Default: 15s
+		Heartbeat time.Duration
+	}
 }
 
 func createDefaultConfiguration() *Configuration {
@@ -58,8 +291,36 @@ func createDefaultConfiguration() *Configuration {
 	c.ErrorRedirect = false
 	c.CreateAliasRoutes = true
 	c.AllowSubController = true
+	c.ControllerLifetime = LifetimeSingleton
+	c.CaseSensitiveRouting = false
+	c.CleanPath = true
+	c.RedirectCleanPath = false
+	c.TrailingSlash = TrailingSlashLenient
 	c.Verbosity.SyncVerbose = false
 	c.Verbosity.SyncVerbose = true
 	c.Verbosity.Writer = os.Stdout
+	c.AccessLog.Enabled = false
+	c.AccessLog.Format = AccessLogCommon
+	c.AccessLog.Writer = os.Stdout
+	c.Metrics.Enabled = false
+	c.Metrics.Path = "/metrics"
+	c.Metrics.Buckets = DefaultMetricsBuckets
+	c.RequestID.Enabled = false
+	c.RequestID.Header = "X-Request-ID"
+	c.CSRF.Enabled = false
+	c.CSRF.Mode = CSRFSession
+	c.CSRF.Header = "X-CSRF-Token"
+	c.CSRF.FormField = "csrf_token"
+	c.CSRF.CookieName = "csrf_token"
+	c.Limits.TimeoutStatus = http.StatusServiceUnavailable
+	c.Compression.Enabled = false
+	c.Compression.MinSize = 1024
+	c.Compression.Encoders = DefaultEncoders
+	c.Compression.SkipContentTypes = DefaultSkipContentTypes
+	c.Caching.Enabled = false
+	c.Caching.WeakETags = false
+	c.Rendering.FormatParam = "format"
+	c.Rendering.PathExtensions = false
+	c.EventStream.Heartbeat = 15 * time.Second
 	return c
 }
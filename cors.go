@@ -0,0 +1,227 @@
+package wrouter
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetaCORS is the metadata key of the CORS policy of a route. Its value is a *CORSPolicy, which takes
+// precedence over Configuration.CORS. See: Route.CORS, Group.CORS
+const MetaCORS = "cors"
+
+// CORSPolicy defines which cross-origin requests are allowed. Preflight requests are answered by the router,
+// with the allowed methods taken from the routes matching the request path.
+type CORSPolicy struct {
+	// AllowedOrigins contains the allowed origins, e.g. "https://example.com". "*" allows all origins unless
+	// AllowCredentials is set, as any site could then act with the credentials of the user. A single "*"
+	// within an origin matches any part of it, e.g. "https://*.example.com".
+	AllowedOrigins []string
+	// AllowedOriginPatterns contains regular expressions matched against the origin, in addition to
+	// AllowedOrigins
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowedHeaders contains the request headers allowed in cross-origin requests. "*" allows all
+	// requested headers. If empty, Accept, Accept-Language, Content-Language, Content-Type, Authorization,
+	// X-Requested-With and X-CSRF-Token are allowed.
+	AllowedHeaders []string
+	// ExposedHeaders contains the response headers scripts are allowed to read
+	ExposedHeaders []string
+	// AllowCredentials allows requests including cookies and authorization headers
+	AllowCredentials bool
+	// MaxAge is the time the result of a preflight request may be cached. Zero omits the header.
+	MaxAge time.Duration
+}
+
+// defaultCORSHeaders are the request headers allowed if CORSPolicy.AllowedHeaders is empty
+var defaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type",
+	"Authorization", "X-Requested-With", "X-CSRF-Token"}
+
+// AllowsOrigin returns true, if the policy allows requests from the origin
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			if p.AllowCredentials {
+				continue
+			}
+			return true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := strings.ToLower(allowed[:i]), strings.ToLower(allowed[i+1:])
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) &&
+				strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range p.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders returns true, if all headers of a comma separated list are allowed
+func (p *CORSPolicy) allowsHeaders(requested string) bool {
+	allowed := p.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = defaultCORSHeaders
+	}
+	for _, header := range splitList(requested) {
+		ok := false
+		for _, a := range allowed {
+			if a == "*" || strings.EqualFold(a, header) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// anyOrigin returns true, if the response is the same for all origins, so that "*" can be sent
+func (p *CORSPolicy) anyOrigin() bool {
+	if p.AllowCredentials {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements the fmt.Stringer interface, e.g. for the exported route table
+func (p *CORSPolicy) String() string {
+	origins := make([]string, 0, len(p.AllowedOrigins)+len(p.AllowedOriginPatterns))
+	origins = append(origins, p.AllowedOrigins...)
+	for _, pattern := range p.AllowedOriginPatterns {
+		origins = append(origins, "/"+pattern.String()+"/")
+	}
+	s := "cors(" + strings.Join(origins, ",")
+	if p.AllowCredentials {
+		s += "; credentials"
+	}
+	return s + ")"
+}
+
+// CORS sets the CORS policy of the route. It returns the route to allow chaining.
+func (r *Route) CORS(policy *CORSPolicy) *Route {
+	return r.SetMeta(MetaCORS, policy)
+}
+
+// CORS returns a copy of the group, which sets the CORS policy on every route added through it, unless the
+// route has its own policy
+func (g *Group) CORS(policy *CORSPolicy) *Group {
+	return g.WithMeta(MetaCORS, policy)
+}
+
+// corsPolicy returns the policy applying to a route, or nil if cross-origin requests are not allowed
+func (r *Router) corsPolicy(route *Route) *CORSPolicy {
+	if route != nil {
+		if policy, ok := route.Metadata[MetaCORS].(*CORSPolicy); ok {
+			return policy
+		}
+	}
+	return r.Configuration.CORS
+}
+
+// isPreflight returns true for CORS preflight requests
+func isPreflight(h *http.Request) bool {
+	return h.Method == "OPTIONS" && h.Header.Get("Origin") != "" &&
+		h.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// applyCORS sets the CORS headers of an actual cross-origin request to the resolved route
+func (r *Router) applyCORS(w http.ResponseWriter, h *http.Request, route *Route) {
+	policy := r.corsPolicy(route)
+	if policy == nil {
+		return
+	}
+	header := w.Header()
+	if !policy.anyOrigin() {
+		header.Add("Vary", "Origin")
+	}
+
+	origin := h.Header.Get("Origin")
+	if !policy.AllowsOrigin(origin) {
+		return
+	}
+	setAllowOrigin(header, policy, origin)
+	if len(policy.ExposedHeaders) != 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+	}
+}
+
+// servePreflight answers a preflight request. The allowed methods are the methods of all routes matching the
+// request path, and the policy is taken from the route matching the requested method. It returns false, if no
+// route matches the path or no policy applies, so that the request is served like any other.
+func (r *Router) servePreflight(w http.ResponseWriter, h *http.Request) bool {
+	requested := strings.ToUpper(h.Header.Get("Access-Control-Request-Method"))
+
+	var methods []string
+	var policyRoute *Route
+	probe := *h
+	for _, method := range AllowedMethods {
+		probe.Method = strings.ToUpper(method)
+		route := r.findRequestRoute(&probe)
+		if route == nil {
+			continue
+		}
+		methods = append(methods, probe.Method)
+		if policyRoute == nil || probe.Method == requested {
+			policyRoute = route
+		}
+	}
+	if policyRoute == nil {
+		return false
+	}
+	policy := r.corsPolicy(policyRoute)
+	if policy == nil {
+		return false
+	}
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := h.Header.Get("Origin")
+	if policy.AllowsOrigin(origin) &&
+		policy.allowsHeaders(h.Header.Get("Access-Control-Request-Headers")) {
+		setAllowOrigin(header, policy, origin)
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if requestedHeaders := h.Header.Get("Access-Control-Request-Headers"); requestedHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", requestedHeaders)
+		}
+		if policy.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge/time.Second)))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func setAllowOrigin(header http.Header, policy *CORSPolicy, origin string) {
+	if policy.anyOrigin() {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,131 @@
+package wrouter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tApiController struct{}
+
+func (t *tApiController) ItemsAction()         {}
+func (t *tApiController) PostPut_ItemsAction() {}
+func (t *tApiController) Delete_ItemsAction()  {}
+
+func TestCORSPreflight(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.CORS = &CORSPolicy{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		AllowCredentials:      true,
+		MaxAge:                10 * time.Minute,
+	}
+	rt.AddController(&tApiController{})
+
+	cases := []struct {
+		origin, method, headers string
+		status                  int
+		allowOrigin             string
+	}{
+		{"https://app.example.com", "PUT", "Content-Type", 204, "https://app.example.com"},
+		{"https://eu.example.org", "DELETE", "", 204, "https://eu.example.org"},
+		{"http://localhost:3000", "POST", "", 204, "http://localhost:3000"},
+		{"https://evil.com", "PUT", "", 204, ""},
+		{"https://app.example.com", "PUT", "X-Custom", 204, ""},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("OPTIONS", "/tapi/items", nil)
+		request.Header.Set("Origin", c.origin)
+		request.Header.Set("Access-Control-Request-Method", c.method)
+		if c.headers != "" {
+			request.Header.Set("Access-Control-Request-Headers", c.headers)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Header().Get("Access-Control-Allow-Origin") != c.allowOrigin {
+			t.Errorf("%s %s: expected %d %q, got %d %q", c.origin, c.method, c.status, c.allowOrigin, rec.Code,
+				rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if c.allowOrigin == "" {
+			continue
+		}
+		if m := rec.Header().Get("Access-Control-Allow-Methods"); m != "GET, POST, PUT, DELETE" {
+			t.Errorf("Unexpected allowed methods %q", m)
+		}
+		if rec.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+			rec.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("Missing credentials or max age in %v", rec.Header())
+		}
+		if vary := strings.Join(rec.Header().Values("Vary"), ","); !strings.Contains(vary, "Origin") ||
+			!strings.Contains(vary, "Access-Control-Request-Method") {
+			t.Errorf("Unexpected Vary header %q", vary)
+		}
+	}
+
+	// Preflights to unknown paths are not answered
+	request := httptest.NewRequest("OPTIONS", "/tapi/unknown", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 404 {
+		t.Errorf("Expected 404 for preflight to unknown path, got %d", rec.Code)
+	}
+}
+
+func TestCORSRoutePolicy(t *testing.T) {
+	rt := NewRouter()
+	public := &CORSPolicy{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Total"}}
+	rt.Group("/public").CORS(public).HandleFunc("/feed", nil, func(w http.ResponseWriter, r *http.Request) {})
+	rt.HandleFunc("/private", nil, func(w http.ResponseWriter, r *http.Request) {})
+
+	request := httptest.NewRequest("GET", "/public/feed", nil)
+	request.Header.Set("Origin", "https://anywhere.com")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Vary") != "" ||
+		rec.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		t.Errorf("Unexpected CORS headers %v", rec.Header())
+	}
+
+	request = httptest.NewRequest("GET", "/private", nil)
+	request.Header.Set("Origin", "https://anywhere.com")
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Route without policy must not allow cross-origin requests")
+	}
+
+	request = httptest.NewRequest("OPTIONS", "/private", nil)
+	request.Header.Set("Origin", "https://anywhere.com")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 404 {
+		t.Errorf("Preflight to route without policy must not be answered, got %d", rec.Code)
+	}
+
+	// All origins cannot be allowed with credentials
+	credentials := &CORSPolicy{AllowedOrigins: []string{"*", "https://app.example.com"}, AllowCredentials: true}
+	rt.Group("/account").CORS(credentials).HandleFunc("/me", nil, func(w http.ResponseWriter, r *http.Request) {})
+	origins := map[string]string{"https://evil.com": "", "https://app.example.com": "https://app.example.com"}
+	for origin, allowed := range origins {
+		request = httptest.NewRequest("GET", "/account/me", nil)
+		request.Header.Set("Origin", origin)
+		rec = httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Header().Get("Access-Control-Allow-Origin") != allowed {
+			t.Errorf("%s: expected allowed origin %q, got %v", origin, allowed, rec.Header())
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	rt.ExportRoutes(buf)
+	if !strings.Contains(buf.String(), "\"cors(*)\"") {
+		t.Errorf("CORS policy missing in route export: %s", buf.String())
+	}
+}
@@ -0,0 +1,135 @@
+package wrouter
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+// CSRFMode defines where the CSRF token of a client is kept. See Configuration.CSRF
+type CSRFMode int
+
+const (
+	// CSRFSession keeps the token in the session of the client (synchronizer token). It requires a
+	// Router.SessionStore.
+	CSRFSession CSRFMode = iota
+	// CSRFDoubleSubmit keeps the token in a cookie, which has to be submitted again in the request header or
+	// form field. It works without sessions.
+	CSRFDoubleSubmit
+)
+
+// MetaCSRFExempt is the metadata key to exempt a route from CSRF protection, e.g. for webhooks authenticated
+// by other means. Its value is a bool or a string parsed by strconv.ParseBool.
+const MetaCSRFExempt = "csrf.exempt"
+
+// csrfSessionKey is the session key under which the token is stored in CSRFSession mode
+const csrfSessionKey = "_csrf"
+
+// CSRFToken is the CSRF token of the current request, to be rendered into forms or handed to scripts. It can be
+// injected into controller actions by declaring an argument of type wrouter.CSRFToken. The token is empty if CSRF
+// protection is disabled.
+type CSRFToken string
+
+type csrfContextKey struct{}
+
+// csrfState holds the token of a request. The token is created on first access.
+type csrfState struct {
+	router *Router
+	w      http.ResponseWriter
+	h      *http.Request
+	token  string
+}
+
+// CSRFTokenFromContext returns the CSRF token of the request the context belongs to. If the client has no token
+// yet, a new one is created and stored in the session or cookie. An empty string is returned, if CSRF protection
+// is disabled.
+func CSRFTokenFromContext(ctx context.Context) string {
+	state, _ := ctx.Value(csrfContextKey{}).(*csrfState)
+	if state == nil {
+		return ""
+	}
+	return state.get()
+}
+
+func (c *csrfState) get() string {
+	if c.token != "" {
+		return c.token
+	}
+
+	cfg := c.router.Configuration.CSRF
+	switch cfg.Mode {
+	case CSRFSession:
+		session := SessionFromContext(c.h.Context())
+		if session == nil {
+			return ""
+		}
+		if c.token = session.String(csrfSessionKey); c.token == "" {
+			c.token = generateSessionID()
+			session.Set(csrfSessionKey, c.token)
+		}
+	case CSRFDoubleSubmit:
+		if cookie, err := c.h.Cookie(cfg.CookieName); err == nil && cookie.Value != "" {
+			c.token = cookie.Value
+			return c.token
+		}
+		c.token = generateSessionID()
+		// The cookie is readable by scripts, so that they can send the token in the header
+		http.SetCookie(c.w, &http.Cookie{
+			Name:     cfg.CookieName,
+			Value:    c.token,
+			Path:     "/",
+			Secure:   c.h.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return c.token
+}
+
+// expected returns the token the client has to submit, without creating one
+func (c *csrfState) expected() string {
+	cfg := c.router.Configuration.CSRF
+	switch cfg.Mode {
+	case CSRFSession:
+		if session := SessionFromContext(c.h.Context()); session != nil {
+			return session.String(csrfSessionKey)
+		}
+	case CSRFDoubleSubmit:
+		if cookie, err := c.h.Cookie(cfg.CookieName); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// protectCSRF stores the CSRF state in the request context and verifies the submitted token of requests with
+// unsafe methods. If the verification fails, 403 is served and nil is returned.
+func (r *Router) protectCSRF(w http.ResponseWriter, h *http.Request, route *Route) *http.Request {
+	state := &csrfState{router: r, w: w}
+	h = h.WithContext(context.WithValue(h.Context(), csrfContextKey{}, state))
+	state.h = h
+
+	if safeMethod(h.Method) || route.Metadata.Bool(MetaCSRFExempt) {
+		return h
+	}
+
+	cfg := r.Configuration.CSRF
+	submitted := h.Header.Get(cfg.Header)
+	if submitted == "" {
+		submitted = h.PostFormValue(cfg.FormField)
+	}
+	expected := state.expected()
+	if expected == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) != 1 {
+		r.serveError(w, h, http.StatusForbidden)
+		return nil
+	}
+	return h
+}
+
+// safeMethod returns true for methods which must not change state, and therefore need no CSRF protection
+func safeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
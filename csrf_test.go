@@ -0,0 +1,88 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type tFormController struct {
+	_ Meta `action:"Post_HookAction" csrf.exempt:"true"`
+}
+
+func (t *tFormController) EditAction(w http.ResponseWriter, token CSRFToken) {
+	w.Write([]byte(token))
+}
+func (t *tFormController) Post_SaveAction(w http.ResponseWriter) { w.Write([]byte("saved")) }
+func (t *tFormController) Post_HookAction(w http.ResponseWriter) { w.Write([]byte("hooked")) }
+
+func TestCSRFSession(t *testing.T) {
+	rt := NewRouter()
+	rt.SessionStore = NewMemoryStore()
+	rt.Configuration.CSRF.Enabled = true
+	rt.AddController(&tFormController{})
+	client := &tSessionClient{t: t, router: rt}
+
+	if rec := client.do("POST", "/tform/save"); rec.Code != 403 || rec.Body.String() != "Forbidden" {
+		t.Errorf("Expected 403 without session, got %d", rec.Code)
+	}
+	token := client.do("GET", "/tform/edit").Body.String()
+	if token == "" || client.cookie == nil {
+		t.Fatalf("Expected a token stored in the session")
+	}
+	if again := client.do("GET", "/tform/edit").Body.String(); again != token {
+		t.Errorf("Token changed between requests: %q %q", token, again)
+	}
+	if rec := client.do("POST", "/tform/save"); rec.Code != 403 {
+		t.Errorf("Expected 403 without token, got %d", rec.Code)
+	}
+	if rec := client.do("POST", "/tform/hook"); rec.Code != 200 || rec.Body.String() != "hooked" {
+		t.Errorf("Exempted route has been rejected: %d", rec.Code)
+	}
+
+	form := url.Values{"csrf_token": {token}}.Encode()
+	request := httptest.NewRequest("POST", "/tform/save", strings.NewReader(form))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(client.cookie)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 200 || rec.Body.String() != "saved" {
+		t.Errorf("Form token has been rejected: %d", rec.Code)
+	}
+
+	request = httptest.NewRequest("POST", "/tform/save", nil)
+	request.Header.Set("X-CSRF-Token", token+"x")
+	request.AddCookie(client.cookie)
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 403 {
+		t.Errorf("Wrong header token has been accepted: %d", rec.Code)
+	}
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.CSRF.Enabled = true
+	rt.Configuration.CSRF.Mode = CSRFDoubleSubmit
+	rt.AddController(&tFormController{})
+	client := &tSessionClient{t: t, router: rt}
+
+	token := client.do("GET", "/tform/edit").Body.String()
+	if client.cookie == nil || client.cookie.Name != "csrf_token" || client.cookie.Value != token {
+		t.Fatalf("Expected the token in a cookie, got %v", client.cookie)
+	}
+	if rec := client.do("POST", "/tform/save"); rec.Code != 403 {
+		t.Errorf("Cookie alone must not be accepted, got %d", rec.Code)
+	}
+
+	request := httptest.NewRequest("POST", "/tform/save", nil)
+	request.Header.Set("X-CSRF-Token", token)
+	request.AddCookie(client.cookie)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+	if rec.Code != 200 {
+		t.Errorf("Submitted token has been rejected: %d", rec.Code)
+	}
+}
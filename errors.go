@@ -0,0 +1,31 @@
+package wrouter
+
+import "net/http"
+
+// ErrorHandler is called whenever the router answers a request with an error status on its own, e.g. if
+// no route matches the request. It can be used to render custom error pages.
+// See: Router.ErrorHandler
+type ErrorHandler interface {
+	HandleError(w http.ResponseWriter, r *http.Request, status int)
+}
+
+// ErrorHandlerFunc is an adapter to allow the use of ordinary functions as ErrorHandler
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, status int)
+
+// HandleError implements the ErrorHandler interface
+func (f ErrorHandlerFunc) HandleError(w http.ResponseWriter, r *http.Request, status int) {
+	f(w, r, status)
+}
+
+// serveError answers the request with the given status, using the configured ErrorHandler if any. Without
+// an ErrorHandler, the status text is written as plain text.
+func (r *Router) serveError(w http.ResponseWriter, h *http.Request, status int) {
+	if r.ErrorHandler != nil {
+		r.ErrorHandler.HandleError(w, h, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write([]byte(http.StatusText(status)))
+}
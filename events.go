@@ -14,6 +14,8 @@ type PreRequestEventContext struct {
 	Request *http.Request
 	// ResponseWriter contains the current ResponseWriter instance
 	ResponseWriter http.ResponseWriter
+	// RequestID contains the ID of the current request, if request IDs are enabled
+	RequestID RequestID
 }
 
 // PostRouteResolveEventContext contains the event context for events which are fired after the controller action has
@@ -24,6 +26,9 @@ type PostRequestEventContext struct {
 	Request        *http.Request
 	ResponseWriter http.ResponseWriter
 	Values         []reflect.Value
+	RequestID      RequestID
+	// Route contains the route which has been called, including its Metadata
+	Route *Route
 }
 
 type PreRequestEvent interface {
@@ -38,6 +43,7 @@ func createPreRequestEventContext(h *http.Request, w http.ResponseWriter) *PreRe
 	return &PreRequestEventContext{
 		Request:        h,
 		ResponseWriter: w,
+		RequestID:      RequestID(RequestIDFromContext(h.Context())),
 	}
 }
 
@@ -46,5 +52,7 @@ func createPostRequestEventContext(h *http.Request, w http.ResponseWriter, vs []
 		Request:        h,
 		ResponseWriter: w,
 		Values:         vs,
+		RequestID:      RequestID(RequestIDFromContext(h.Context())),
+		Route:          RouteFromContext(h.Context()),
 	}
 }
@@ -0,0 +1,154 @@
+package wrouter
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// FilterContext is passed to controller filters. Besides the injector context of the request, it allows to
+// abort the request before the action is called.
+type FilterContext struct {
+	*InjectorContext
+	// Values contains the values returned by the action. It is only set for AfterFilters.
+	Values []reflect.Value
+
+	aborted bool
+	status  int
+}
+
+// Abort stops the request. No further filters and, if not called yet, no action are executed. If status is
+// not 0, the request is answered with it through the router's error handling; with 0, the filter is expected
+// to have written the response itself.
+func (c *FilterContext) Abort(status int) {
+	c.aborted = true
+	c.status = status
+}
+
+// Aborted returns true, if the request has been aborted by a filter
+func (c *FilterContext) Aborted() bool {
+	return c.aborted
+}
+
+// BeforeFilter can be implemented by controllers to be called before every action of the controller and its
+// sub-controllers. Filters of parent controllers are called first.
+type BeforeFilter interface {
+	Before(*FilterContext)
+}
+
+// AfterFilter can be implemented by controllers to be called after every action of the controller and its
+// sub-controllers. Filters of sub-controllers are called first.
+type AfterFilter interface {
+	After(*FilterContext)
+}
+
+// AroundFilter can be implemented by controllers to wrap every action of the controller and its
+// sub-controllers. The filter must call next to continue; filters of parent controllers wrap the filters of
+// sub-controllers.
+type AroundFilter interface {
+	Around(ctx *FilterContext, next func())
+}
+
+// Filter names used by MetaSkipFilters. Besides these, a controller name (e.g. "AdminController") skips all
+// filters of the controller and a qualified name (e.g. "AdminController.Before") skips one filter.
+const (
+	SkipAllFilters    = "*"
+	SkipBeforeFilters = "Before"
+	SkipAfterFilters  = "After"
+	SkipAroundFilters = "Around"
+)
+
+// filterChain contains the filters applying to one request, ordered from the registered controller to the
+// controller of the route
+type filterChain struct {
+	before []BeforeFilter
+	after  []AfterFilter
+	around []AroundFilter
+}
+
+func (fc *filterChain) empty() bool {
+	return len(fc.before) == 0 && len(fc.after) == 0 && len(fc.around) == 0
+}
+
+// createFilterChain collects the filters of all controller instances serving the request, except for the
+// filters skipped by the route metadata
+func createFilterChain(route *Route, chain []reflect.Value) *filterChain {
+	fc := new(filterChain)
+	skip, _ := route.Metadata[MetaSkipFilters].([]string)
+
+	for _, controller := range chain {
+		c := controller.Interface()
+		name := controller.Type().Elem().Name()
+		if f, ok := c.(BeforeFilter); ok && !skipsFilter(skip, name, SkipBeforeFilters) {
+			fc.before = append(fc.before, f)
+		}
+		if f, ok := c.(AfterFilter); ok && !skipsFilter(skip, name, SkipAfterFilters) {
+			fc.after = append(fc.after, f)
+		}
+		if f, ok := c.(AroundFilter); ok && !skipsFilter(skip, name, SkipAroundFilters) {
+			fc.around = append(fc.around, f)
+		}
+	}
+	return fc
+}
+
+func skipsFilter(skip []string, controller, kind string) bool {
+	for _, s := range skip {
+		if s == SkipAllFilters || s == kind || s == controller || s == controller+"."+kind {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs the before filters, the action wrapped by the around filters and the after filters. It
+// returns false, if a filter aborted the request.
+func (fc *filterChain) run(ctx *FilterContext, action func() []reflect.Value) bool {
+	for _, f := range fc.before {
+		f.Before(ctx)
+		if ctx.aborted {
+			return false
+		}
+	}
+
+	called := false
+	next := func() {
+		ctx.Values = action()
+		called = true
+	}
+	for i := len(fc.around) - 1; i >= 0; i-- {
+		f, inner := fc.around[i], next
+		next = func() {
+			if !ctx.aborted {
+				f.Around(ctx, inner)
+			}
+		}
+	}
+	next()
+	if ctx.aborted || !called {
+		return false
+	}
+
+	for i := len(fc.after) - 1; i >= 0; i-- {
+		fc.after[i].After(ctx)
+		if ctx.aborted {
+			return false
+		}
+	}
+	return true
+}
+
+// abortRequest answers a request aborted by a filter, unless the response has already been written
+func (r *Router) abortRequest(ctx *FilterContext, w http.ResponseWriter, h *http.Request) {
+	if ctx.status != 0 && !responseWritten(w) {
+		r.serveError(w, h, ctx.status)
+	}
+}
+
+// responseWritten returns true, if the header of the response has been sent already. For unknown
+// ResponseWriters, false is returned.
+func responseWritten(w http.ResponseWriter) bool {
+	if rw, ok := w.(interface{ Written() bool }); ok {
+		return rw.Written()
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var tFilterLog []string
+
+type tAdminController struct {
+	_ *tReportsController
+}
+
+type tReportsController struct{}
+
+func (t *tAdminController) Before(ctx *FilterContext) {
+	tFilterLog = append(tFilterLog, "admin.before")
+	if ctx.Request.Header.Get("X-Role") != "admin" {
+		ctx.Abort(http.StatusForbidden)
+	}
+}
+
+func (t *tAdminController) Around(ctx *FilterContext, next func()) {
+	tFilterLog = append(tFilterLog, "admin.around")
+	next()
+	tFilterLog = append(tFilterLog, "admin.around.done")
+}
+
+func (t *tAdminController) IndexAction()  { tFilterLog = append(tFilterLog, "admin.index") }
+func (t *tAdminController) PublicAction() { tFilterLog = append(tFilterLog, "admin.public") }
+
+func (t *tAdminController) RouteMetadata() map[string]Metadata {
+	return map[string]Metadata{
+		"PublicAction": {MetaSkipFilters: []string{"tAdminController.Before"}},
+	}
+}
+
+func (t *tReportsController) After(ctx *FilterContext) {
+	tFilterLog = append(tFilterLog, "reports.after "+ctx.Values[0].String())
+}
+
+func (t *tReportsController) ListAction() string {
+	tFilterLog = append(tFilterLog, "reports.list")
+	return "result"
+}
+
+func TestControllerFilters(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tAdminController{})
+
+	serve := func(uri, role string) int {
+		tFilterLog = nil
+		request := httptest.NewRequest("GET", uri, nil)
+		request.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		return rec.Code
+	}
+
+	if status := serve("/tadmin/index", "user"); status != 403 || strings.Join(tFilterLog, ",") != "admin.before" {
+		t.Errorf("Expected abort with 403, got %d %v", status, tFilterLog)
+	}
+	if status := serve("/tadmin/index", "admin"); status != 200 ||
+		strings.Join(tFilterLog, ",") != "admin.before,admin.around,admin.index,admin.around.done" {
+		t.Errorf("Unexpected filter order %d %v", status, tFilterLog)
+	}
+	if status := serve("/tadmin/public", "user"); status != 200 ||
+		strings.Join(tFilterLog, ",") != "admin.around,admin.public,admin.around.done" {
+		t.Errorf("Expected skipped before filter, got %d %v", status, tFilterLog)
+	}
+
+	// Filters are inherited by sub-controllers
+	if status := serve("/tadmin/treports/list", "user"); status != 403 {
+		t.Errorf("Expected inherited filter to abort, got %d %v", status, tFilterLog)
+	}
+	if status := serve("/tadmin/treports/list", "admin"); status != 200 || strings.Join(tFilterLog, ",") !=
+		"admin.before,admin.around,reports.list,admin.around.done,reports.after result" {
+		t.Errorf("Unexpected filter order %d %v", status, tFilterLog)
+	}
+}
+
+type tTimelineController struct{}
+
+func (t *tTimelineController) Before(w http.ResponseWriter) { w.Write([]byte("before")) }
+func (t *tTimelineController) After(w http.ResponseWriter)  { w.Write([]byte("after")) }
+
+func TestHookNamedActions(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tTimelineController{})
+
+	for _, action := range []string{"before", "after"} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", "/ttimeline/"+action, nil))
+		if rec.Code != 200 || rec.Body.String() != action {
+			t.Errorf("Expected %s action, got %d %q", action, rec.Code, rec.Body.String())
+		}
+	}
+}
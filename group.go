@@ -0,0 +1,103 @@
+package wrouter
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Group is a set of routes sharing a path prefix and optionally a host pattern. Routes added through a
+// Group are added to the Router with the prefix prepended to their path.
+type Group struct {
+	router   *Router
+	prefix   string
+	host     string
+	metadata Metadata
+}
+
+// Group creates a new route group with the given path prefix
+func (r *Router) Group(prefix string) *Group {
+	return &Group{router: r, prefix: normalizePath(prefix)}
+}
+
+// Host creates a new route group, whose routes are only matched for requests to the given host pattern.
+// See: Group.Host
+func (r *Router) Host(pattern string) *Group {
+	return &Group{router: r, host: pattern}
+}
+
+// Group creates a sub-group, whose prefix is appended to the prefix of the current group. The host pattern
+// is inherited.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{router: g.router, prefix: joinPath(g.prefix, normalizePath(prefix)), host: g.host,
+		metadata: g.metadata}
+}
+
+// Host returns a copy of the group bound to the given host pattern. Supported are exact hosts
+// ("example.com"), wildcard subdomains ("*.example.com") and subdomain parameters ("{tenant}.example.com").
+// Routes bound to a host take precedence over routes with the same path which are not.
+func (g *Group) Host(pattern string) *Group {
+	return &Group{router: g.router, prefix: g.prefix, host: pattern, metadata: g.metadata}
+}
+
+// AddController resolves the routes of the controller and adds them to the group
+func (g *Group) AddController(controller interface{}) {
+	for _, route := range g.router.resolveController(controller) {
+		g.AddRoute(route)
+	}
+}
+
+// AddRoute adds a route to the group. The prefix is prepended to the route path, the host pattern is set if
+// the route is not bound to a host yet, and the group metadata is added for keys the route does not set.
+func (g *Group) AddRoute(route *Route) {
+	route.Path = joinPath(g.prefix, route.Path)
+	if route.Host == "" {
+		route.Host = g.host
+	}
+	for key, value := range g.metadata {
+		if key == MetaPolicies {
+			// policies of the group apply in addition to those of the route
+			route.Require(value.([]Policy)...)
+		} else if _, exists := route.Metadata[key]; !exists {
+			route.SetMeta(key, value)
+		}
+	}
+	g.router.AddRoute(route)
+}
+
+// Handle registers an http.Handler in the group. See: Router.Handle
+func (g *Group) Handle(pattern string, methods []string, handler http.Handler) *Route {
+	route := newHandlerRoute(pattern, methods, handler)
+	g.AddRoute(route)
+	return route
+}
+
+// HandleFunc registers an http.HandlerFunc in the group. See: Router.HandleFunc
+func (g *Group) HandleFunc(pattern string, methods []string, fn func(http.ResponseWriter, *http.Request)) *Route {
+	return g.Handle(pattern, methods, http.HandlerFunc(fn))
+}
+
+// Mount mounts an http.Handler in the group. See: Router.Mount
+func (g *Group) Mount(prefix string, handler http.Handler) *Route {
+	route := newMountRoute(prefix, handler)
+	g.AddRoute(route)
+	return route
+}
+
+// Static serves the files of a directory in the group. See: Router.Static
+func (g *Group) Static(prefix, dir string, opts *StaticOptions) *Route {
+	return g.StaticFS(prefix, os.DirFS(dir), opts)
+}
+
+// StaticFS serves the files of a fs.FS in the group. See: Router.StaticFS
+func (g *Group) StaticFS(prefix string, fsys fs.FS, opts *StaticOptions) *Route {
+	route := g.router.newStaticRoute(prefix, fsys, opts)
+	g.AddRoute(route)
+	return route
+}
+
+// joinPath joins two normalized paths
+func joinPath(prefix, p string) string {
+	return strings.Trim(prefix+"/"+p, "/")
+}
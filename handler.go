@@ -0,0 +1,102 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Handle registers an http.Handler under the given path for the given HTTP methods. If no methods are
+// given, the route will be callable by GET. The returned Route can be used for further adjustments.
+func (r *Router) Handle(pattern string, methods []string, handler http.Handler) *Route {
+	route := newHandlerRoute(pattern, methods, handler)
+	r.AddRoute(route)
+	return route
+}
+
+// HandleFunc registers an http.HandlerFunc under the given path for the given HTTP methods.
+// See: Router.Handle
+func (r *Router) HandleFunc(pattern string, methods []string, fn func(http.ResponseWriter, *http.Request)) *Route {
+	return r.Handle(pattern, methods, http.HandlerFunc(fn))
+}
+
+// Mount registers an http.Handler for the given prefix and every path below, for all allowed methods. The
+// handler receives the request with the prefix stripped from the path, so that sub-applications like a
+// http.ServeMux or net/http/pprof can be mounted without knowing their prefix. Routes matching the path
+// exactly take precedence over mounted handlers; of several mounted handlers the longest prefix wins.
+func (r *Router) Mount(prefix string, handler http.Handler) *Route {
+	route := newMountRoute(prefix, handler)
+	r.AddRoute(route)
+	return route
+}
+
+func newHandlerRoute(pattern string, methods []string, handler http.Handler) *Route {
+	route := new(Route)
+	route.Handler = handler
+	route.Path = normalizePath(pattern)
+	for _, method := range methods {
+		route.AddMethod(strings.ToUpper(method))
+	}
+	if len(route.Methods) == 0 {
+		route.AddMethod("GET")
+	}
+	return route
+}
+
+func newMountRoute(prefix string, handler http.Handler) *Route {
+	route := new(Route)
+	route.Handler = handler
+	route.Prefix = true
+	route.Path = normalizePath(prefix)
+	for _, method := range AllowedMethods {
+		route.AddMethod(strings.ToUpper(method))
+	}
+	return route
+}
+
+// serveHandler calls the http.Handler of a route. Mounted handlers receive the path with the prefix stripped.
+func (r *Router) serveHandler(route *Route, w http.ResponseWriter, h *http.Request) {
+	if route.Prefix {
+		h = stripPathPrefix(h, route.Path)
+	}
+	route.Handler.ServeHTTP(w, h)
+}
+
+// normalizePath brings a path into the form used by Route.Path: without duplicate, leading and trailing slashes
+func normalizePath(p string) string {
+	return strings.Trim(cleanSlashes.ReplaceAllString(p, "/"), "/")
+}
+
+// stripPathPrefix returns a shallow copy of the request, with as many path segments removed as the
+// prefix contains. Segments are counted instead of compared, as the route path may differ in case.
+func stripPathPrefix(h *http.Request, prefix string) *http.Request {
+	if prefix == "" {
+		return h
+	}
+	n := strings.Count(prefix, "/") + 1
+
+	h2 := new(http.Request)
+	*h2 = *h
+	h2.URL = new(url.URL)
+	*h2.URL = *h.URL
+	if h.URL.RawPath == "" {
+		h2.URL.Path = stripSegments(h.URL.Path, n)
+		return h2
+	}
+
+	// Escaped slashes must not be counted as segments, therefore the escaped path is stripped
+	h2.URL.RawPath = stripSegments(h.URL.RawPath, n)
+	if p, err := url.PathUnescape(h2.URL.RawPath); err == nil {
+		h2.URL.Path = p
+	}
+	return h2
+}
+
+func stripSegments(p string, n int) string {
+	p = cleanSlashes.ReplaceAllString(p, "/")
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", n+1)
+	if len(parts) <= n {
+		return "/"
+	}
+	return "/" + parts[n]
+}
@@ -0,0 +1,58 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleAndMount(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+
+	rt.HandleFunc("/status", []string{"get", "head"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Files/Readme", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) })
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("root " + r.URL.Path)) })
+	rt.Mount("/sub/app", mux)
+	rt.Mount("/sub", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("sub")) }))
+
+	var seen []string
+	rt.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := RouteFromContext(r.Context()); route != nil {
+				seen = append(seen, route.Path)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	cases := []struct {
+		method, uri string
+		status      int
+		body        string
+	}{
+		{"GET", "/status", 200, "ok"},
+		{"HEAD", "/status", 200, ""},
+		{"POST", "/status", 404, "Not Found"},
+		{"GET", "/sub/app/Files/Readme?x=1", 200, "/Files/Readme"},
+		{"DELETE", "/sub/app", 200, "root /"},
+		{"GET", "/sub/other", 200, "sub"},
+		{"GET", "/subway", 404, "Not Found"},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(c.method, c.uri, nil))
+		if rec.Code != c.status || (c.method != "HEAD" && rec.Body.String() != c.body) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", c.method, c.uri, c.status, c.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	if len(seen) != 5 || seen[0] != "status" || seen[3] != "sub/app" || seen[4] != "sub" {
+		t.Errorf("Middleware did not see the resolved routes: %v", seen)
+	}
+}
@@ -0,0 +1,104 @@
+package wrouter
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// HostParams contains the values matched by the host pattern of a route. Parameters like {tenant} in
+// "{tenant}.example.com" are stored by their name, the labels matched by a leading wildcard in "*.example.com"
+// are stored by "*". HostParams can be injected into controller actions by declaring an argument of type
+// wrouter.HostParams, or retrieved by HostParamsFromContext.
+type HostParams map[string]string
+
+type hostParamsContextKey struct{}
+
+// HostParamsFromContext returns the HostParams of the request the context belongs to. It is nil, if the
+// route of the request is not bound to a host pattern.
+func HostParamsFromContext(ctx context.Context) HostParams {
+	params, _ := ctx.Value(hostParamsContextKey{}).(HostParams)
+	return params
+}
+
+// hostPattern is a compiled host pattern. Supported are exact hosts ("example.com"), a leading wildcard
+// matching one or more labels ("*.example.com") and parameters matching exactly one label
+// ("{tenant}.example.com").
+type hostPattern struct {
+	labels   []string
+	wildcard bool
+}
+
+func compileHostPattern(pattern string) *hostPattern {
+	p := new(hostPattern)
+	pattern = strings.ToLower(strings.Trim(pattern, "."))
+	if strings.HasPrefix(pattern, "*.") {
+		p.wildcard = true
+		pattern = pattern[2:]
+	}
+	p.labels = strings.Split(pattern, ".")
+	return p
+}
+
+// match matches the host of a request, which may contain a port, against the pattern
+func (p *hostPattern) match(host string) (HostParams, bool) {
+	host = normalizeHost(host)
+	labels := strings.Split(host, ".")
+	if len(labels) < len(p.labels) || (!p.wildcard && len(labels) != len(p.labels)) {
+		return nil, false
+	}
+	if p.wildcard && len(labels) == len(p.labels) {
+		return nil, false
+	}
+
+	var params HostParams
+	offset := len(labels) - len(p.labels)
+	for i, label := range p.labels {
+		value := labels[offset+i]
+		if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+			if value == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(HostParams)
+			}
+			params[label[1:len(label)-1]] = value
+		} else if label != value {
+			return nil, false
+		}
+	}
+
+	if p.wildcard {
+		if params == nil {
+			params = make(HostParams)
+		}
+		params["*"] = strings.Join(labels[:offset], ".")
+	}
+	return params, true
+}
+
+// specificity returns the amount of literal labels of the pattern, at most 255. A wildcard pattern is less
+// specific than a pattern with the same labels but without wildcard.
+func (p *hostPattern) specificity() int {
+	n := 0
+	for _, label := range p.labels {
+		if !strings.HasPrefix(label, "{") {
+			n += 2
+		}
+	}
+	if !p.wildcard {
+		n++
+	}
+	if n > 255 {
+		n = 255
+	}
+	return n
+}
+
+// normalizeHost removes the port and a trailing dot from a host and converts it to lower case
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,45 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type tTenantController struct{}
+
+func (t *tTenantController) ShowAction(w http.ResponseWriter, params HostParams) {
+	w.Write([]byte("tenant " + params["tenant"]))
+}
+
+func TestHostRouting(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc("/ttenant/show", nil, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("any host")) })
+	rt.Host("{tenant}.example.com").AddController(&tTenantController{})
+	rt.Host("*.static.example.com").Group("/files").HandleFunc("/", nil,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("wildcard " + HostParamsFromContext(r.Context())["*"]))
+		})
+	rt.Host("api.example.com").HandleFunc("/ttenant/show", nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api"))
+	})
+
+	cases := []struct{ host, uri, body string }{
+		{"acme.example.com", "/ttenant/show", "tenant acme"},
+		{"Other.Example.com:8080", "/ttenant/show", "tenant other"},
+		{"api.example.com", "/ttenant/show", "api"},
+		{"example.com", "/ttenant/show", "any host"},
+		{"a.b.example.com", "/ttenant/show", "any host"},
+		{"eu.cdn.static.example.com", "/files", "wildcard eu.cdn"},
+		{"static.example.com", "/files", "Not Found"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.uri, nil)
+		request.Host = c.host
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Body.String() != c.body {
+			t.Errorf("%s%s: expected %q, got %q", c.host, c.uri, c.body, rec.Body.String())
+		}
+	}
+}
@@ -7,6 +7,12 @@ type InjectorContext struct {
 	Route          *Route
 	Router         *Router
 	ResponseWriter http.ResponseWriter
+	RequestID      RequestID
+	// Controller contains the controller instance serving the current request
+	Controller Controller
+
+	// eventStream is the EventStream injected into the action, which is closed when the action returns
+	eventStream *EventStream
 }
 
 func createInjectorContext(request *http.Request, route *Route, router *Router, rwriter http.ResponseWriter) *InjectorContext {
@@ -15,6 +21,7 @@ func createInjectorContext(request *http.Request, route *Route, router *Router,
 		Route:          route,
 		Router:         router,
 		ResponseWriter: rwriter,
+		RequestID:      RequestID(RequestIDFromContext(request.Context())),
 	}
 }
 
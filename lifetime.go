@@ -0,0 +1,134 @@
+package wrouter
+
+import "reflect"
+
+// ControllerLifetime defines how instances of a controller are shared between requests.
+// See: Configuration.ControllerLifetime and Router.AddControllerFactory
+type ControllerLifetime int
+
+const (
+	// LifetimeSingleton shares the registered controller instance between all requests. Controllers must not
+	// hold per-request state.
+	LifetimeSingleton ControllerLifetime = iota
+	// LifetimePrototype creates a shallow copy of the registered controller for every request, so that all
+	// fields set on registration, e.g. dependencies, are available to every copy.
+	LifetimePrototype
+	// LifetimePerRequest calls a factory for every request. See: Router.AddControllerFactory
+	LifetimePerRequest
+)
+
+// BeforeActionHook can be implemented by controllers to be called on the instance serving the request, right
+// before the action is called.
+type BeforeActionHook interface {
+	BeforeAction(*InjectorContext)
+}
+
+// AfterActionHook can be implemented by controllers to be called on the instance serving the request, right
+// after the action has returned.
+type AfterActionHook interface {
+	AfterAction(*InjectorContext)
+}
+
+// AddControllerFactory will add a controller, of which a new instance is created by the factory for every
+// request. The factory is called once on registration to resolve the routes.
+func (r *Router) AddControllerFactory(factory func() Controller) {
+	for _, route := range r.resolveControllerFactory(factory) {
+		r.AddRoute(route)
+	}
+}
+
+// AddControllerFactory adds a controller created per request to the group. See: Router.AddControllerFactory
+func (g *Group) AddControllerFactory(factory func() Controller) {
+	for _, route := range g.router.resolveControllerFactory(factory) {
+		g.AddRoute(route)
+	}
+}
+
+// resolveController resolves the routes of a controller and applies the configured lifetime to them
+func (r *Router) resolveController(controller Controller) []*Route {
+	routes, err := r.RouteResolver.Resolve(controller)
+	if err != nil {
+		panic(err)
+	}
+	for _, route := range routes {
+		route.lifetime = r.Configuration.ControllerLifetime
+	}
+	return routes
+}
+
+func (r *Router) resolveControllerFactory(factory func() Controller) []*Route {
+	routes, err := r.RouteResolver.Resolve(factory())
+	if err != nil {
+		panic(err)
+	}
+	for _, route := range routes {
+		route.lifetime = LifetimePerRequest
+		route.factory = factory
+	}
+	return routes
+}
+
+// controllerChain returns the controller instances serving a request, from the registered controller to the
+// controller of the route. Depending on the lifetime, the instances are shared or created for the request.
+func (r *Router) controllerChain(route *Route) []reflect.Value {
+	if route.chain == nil {
+		return []reflect.Value{reflect.ValueOf(route.Controller)}
+	}
+
+	chain := make([]reflect.Value, len(route.chain))
+	switch route.lifetime {
+	case LifetimePerRequest:
+		chain[0] = reflect.ValueOf(route.factory())
+		for i, field := range route.fields {
+			chain[i+1] = subController(chain[i], field)
+		}
+	case LifetimePrototype:
+		for i, controller := range route.chain {
+			chain[i] = copyController(reflect.ValueOf(controller))
+		}
+	default:
+		for i, controller := range route.chain {
+			chain[i] = reflect.ValueOf(controller)
+		}
+	}
+	return chain
+}
+
+// subController returns the sub-controller embedded in the given field of the parent. If the field is exported
+// and set, its value is used. Otherwise a new instance is created, whose zero exported fields are wired with
+// the values of the parent's exported fields of the same name and a compatible type.
+func subController(parent reflect.Value, field int) reflect.Value {
+	sf := parent.Elem().Type().Field(field)
+	fv := parent.Elem().Field(field)
+	if sf.PkgPath == "" && !fv.IsNil() {
+		return fv
+	}
+
+	sub := reflect.New(sf.Type.Elem())
+	wireDependencies(parent, sub)
+	return sub
+}
+
+func wireDependencies(parent, child reflect.Value) {
+	pe, ce := parent.Elem(), child.Elem()
+	for i := 0; i < ce.NumField(); i++ {
+		cf := ce.Type().Field(i)
+		if cf.PkgPath != "" || !ce.Field(i).IsZero() {
+			continue
+		}
+		pf, ok := pe.Type().FieldByName(cf.Name)
+		if !ok || pf.PkgPath != "" || len(pf.Index) != 1 || !pf.Type.AssignableTo(cf.Type) {
+			continue
+		}
+		if pv := pe.Field(pf.Index[0]); !pv.IsZero() {
+			ce.Field(i).Set(pv)
+		}
+	}
+}
+
+// copyController creates a shallow copy of a controller
+func copyController(controller reflect.Value) reflect.Value {
+	c := reflect.New(controller.Type().Elem())
+	c.Elem().Set(controller.Elem())
+	return c
+}
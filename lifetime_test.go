@@ -0,0 +1,85 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type tDependency struct{ name string }
+
+type tStatefulController struct {
+	Dep   *tDependency
+	Child *tChildController
+	calls int
+	log   []string
+}
+
+type tChildController struct {
+	Dep *tDependency
+}
+
+func (t *tStatefulController) BeforeAction(ctx *InjectorContext) { t.log = append(t.log, "before") }
+func (t *tStatefulController) AfterAction(ctx *InjectorContext)  { t.log = append(t.log, "after") }
+
+func (t *tStatefulController) CountAction(w http.ResponseWriter) {
+	t.calls++
+	t.log = append(t.log, "action")
+	w.Write([]byte(strconv.Itoa(t.calls) + " " + t.Dep.name + " " + strconv.Itoa(len(t.log))))
+}
+
+func (t *tChildController) NameAction(w http.ResponseWriter) { w.Write([]byte(t.Dep.name)) }
+
+func TestControllerLifetime(t *testing.T) {
+	serve := func(rt *Router, uri string) string {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+		return rec.Body.String()
+	}
+
+	// Singleton: state is shared, hooks are called around the action
+	rt := NewRouter()
+	singleton := &tStatefulController{Dep: &tDependency{"dep"}}
+	rt.AddController(singleton)
+	serve(rt, "/tstateful/count")
+	if body := serve(rt, "/tstateful/count"); body != "2 dep 5" {
+		t.Errorf("Unexpected singleton response %q", body)
+	}
+	if len(singleton.log) != 6 || singleton.log[0] != "before" || singleton.log[2] != "after" {
+		t.Errorf("Unexpected hook calls %v", singleton.log)
+	}
+	if rt.RequestResolver.Resolve(httptest.NewRequest("GET", "/tstateful/beforeaction", nil)) != nil {
+		t.Error("Hook method has been resolved as action")
+	}
+
+	// Sub-controllers are wired with the dependencies of the parent
+	if body := serve(rt, "/tstateful/tchild/name"); body != "dep" {
+		t.Errorf("Expected wired dependency, got %q", body)
+	}
+
+	// Prototype: every request gets a copy including the dependencies
+	rt = NewRouter()
+	rt.Configuration.ControllerLifetime = LifetimePrototype
+	prototype := &tStatefulController{Dep: &tDependency{"proto"}}
+	rt.AddController(prototype)
+	serve(rt, "/tstateful/count")
+	if body := serve(rt, "/tstateful/count"); body != "1 proto 2" || prototype.calls != 0 {
+		t.Errorf("Unexpected prototype response %q", body)
+	}
+
+	// Per request: the factory is called for every request
+	rt = NewRouter()
+	created := 0
+	rt.AddControllerFactory(func() Controller {
+		created++
+		return &tStatefulController{Dep: &tDependency{"factory" + strconv.Itoa(created)}}
+	})
+	serve(rt, "/tstateful/count")
+	if body := serve(rt, "/tstateful/count"); body != "1 factory3 2" {
+		t.Errorf("Unexpected per-request response %q", body)
+	}
+	if body := serve(rt, "/tstateful/tchild/name"); body != "factory4" {
+		t.Errorf("Expected sub-controller wired from the factory instance, got %q", body)
+	}
+}
@@ -0,0 +1,278 @@
+package wrouter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metadata keys overriding the limits of Configuration.Limits per route, e.g. for upload endpoints. Values
+// are either of the native type (int64 and time.Duration), or strings as declared by struct tags, e.g.
+// `limits.body:"50MB" limits.timeout:"2m"`.
+const (
+	// MetaMaxBodySize is the maximum request body size in bytes. Strings may use the suffixes KB, MB and GB.
+	MetaMaxBodySize = "limits.body"
+	// MetaTimeout is the handler timeout, strings are parsed by time.ParseDuration
+	MetaTimeout = "limits.timeout"
+	// MetaReadTimeout is the timeout for reading the request body, strings are parsed by time.ParseDuration
+	MetaReadTimeout = "limits.read_timeout"
+)
+
+// MaxBodySize sets the maximum request body size of the route. It returns the route to allow chaining.
+func (r *Route) MaxBodySize(n int64) *Route {
+	return r.SetMeta(MetaMaxBodySize, n)
+}
+
+// Timeout sets the handler timeout of the route. It returns the route to allow chaining.
+func (r *Route) Timeout(d time.Duration) *Route {
+	return r.SetMeta(MetaTimeout, d)
+}
+
+// MaxBodySize returns a copy of the group, which sets the maximum request body size on every route added
+// through it, unless the route has its own limit
+func (g *Group) MaxBodySize(n int64) *Group {
+	return g.WithMeta(MetaMaxBodySize, n)
+}
+
+// Timeout returns a copy of the group, which sets the handler timeout on every route added through it, unless
+// the route has its own timeout
+func (g *Group) Timeout(d time.Duration) *Group {
+	return g.WithMeta(MetaTimeout, d)
+}
+
+// limitedBody records whether the body limit has been exceeded while reading. The body may still be read by
+// the action of a timed out request, so the flag is accessed atomically.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded int32
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		atomic.StoreInt32(&b.exceeded, 1)
+	}
+	return n, err
+}
+
+// isExceeded returns true, if reading the body has failed because of the limit
+func (b *limitedBody) isExceeded() bool {
+	return atomic.LoadInt32(&b.exceeded) != 0
+}
+
+// limitBody enforces the maximum body size of the route. Requests announcing a larger body are answered with
+// 413 immediately, and nil is returned. Otherwise, reading beyond the limit fails with *http.MaxBytesError.
+func (r *Router) limitBody(w http.ResponseWriter, h *http.Request, route *Route) (*http.Request, *limitedBody) {
+	limit := r.Configuration.Limits.MaxBodySize
+	if v, ok := route.Metadata[MetaMaxBodySize]; ok {
+		limit = metaSize(v)
+	}
+	if limit <= 0 || h.Body == nil || h.Body == http.NoBody {
+		return h, nil
+	}
+
+	if h.ContentLength > limit {
+		w.Header().Set("Connection", "close")
+		r.serveError(w, h, http.StatusRequestEntityTooLarge)
+		return nil, nil
+	}
+
+	body := &limitedBody{ReadCloser: http.MaxBytesReader(w, h.Body, limit)}
+	limited := *h
+	limited.Body = body
+	return &limited, body
+}
+
+// setReadDeadline limits the time for reading the request body, if supported by the ResponseWriter
+func (r *Router) setReadDeadline(w http.ResponseWriter, route *Route) {
+	timeout := r.Configuration.Limits.ReadTimeout
+	if v, ok := route.Metadata[MetaReadTimeout]; ok {
+		timeout = metaDuration(v)
+	}
+	if timeout > 0 {
+		http.NewResponseController(w).SetReadDeadline(time.Now().Add(timeout))
+	}
+}
+
+// routeTimeout returns the handler timeout of the route, or 0 if there is none. Actions streaming events have
+// no timeout, as the buffered response would never reach the client.
+func (r *Router) routeTimeout(route *Route) time.Duration {
+	if streamsEvents(route) {
+		return 0
+	}
+	if v, ok := route.Metadata[MetaTimeout]; ok {
+		return metaDuration(v)
+	}
+	return r.Configuration.Limits.Timeout
+}
+
+// serveWithTimeout calls serve with a request context cancelled after the timeout. The response is buffered,
+// and only sent if serve returns in time. Otherwise, the configured timeout status is served and the buffered
+// response discarded. The action keeps running until it observes the cancelled context, its writes fail with
+// http.ErrHandlerTimeout, and the session is not saved. Panics of the action after the timeout are written to
+// the standard logger. Responses cannot be streamed by routes with a timeout. It returns true, if the request
+// timed out.
+func (r *Router) serveWithTimeout(w http.ResponseWriter, h *http.Request, route *Route, timeout time.Duration,
+	serve func(http.ResponseWriter, *http.Request, *Route)) bool {
+	ctx, cancel := context.WithTimeout(h.Context(), timeout)
+	defer cancel()
+	h = h.WithContext(ctx)
+
+	tw := &timeoutWriter{header: w.Header().Clone()}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				// The timeout is checked while holding the lock, so that the panic is either propagated or
+				// reported after the timeout
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				if tw.timedOut {
+					logLatePanic(h, p)
+				} else {
+					panicked <- p
+				}
+			}
+		}()
+		serve(tw, h, route)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		header := w.Header()
+		for key := range header {
+			if _, ok := tw.header[key]; !ok {
+				header.Del(key)
+			}
+		}
+		for key, values := range tw.header {
+			header[key] = values
+		}
+		if tw.status != 0 {
+			w.WriteHeader(tw.status)
+		}
+		// Empty responses are left to be completed by the caller, e.g. by the 413 of an exceeded body limit
+		if tw.buf.Len() > 0 {
+			w.Write(tw.buf.Bytes())
+		}
+		return false
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+		// A panic racing with the timeout is reported like later ones
+		select {
+		case p := <-panicked:
+			logLatePanic(h, p)
+		default:
+		}
+		// The action keeps running, its changes of the session are discarded
+		abandonSession(h.Context())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.serveError(w, h, r.Configuration.Limits.TimeoutStatus)
+		}
+		return true
+	}
+}
+
+// logLatePanic writes a panic of an action, which occurred after its request timed out, to the standard logger
+func logLatePanic(h *http.Request, p interface{}) {
+	log.Printf("wrouter: panic serving %s %s after the timeout: %v\n%s", h.Method, h.URL, p, debug.Stack())
+}
+
+// timeoutWriter buffers the response of a route with a timeout
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+// Header implements the http.ResponseWriter interface
+func (t *timeoutWriter) Header() http.Header {
+	return t.header
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (t *timeoutWriter) WriteHeader(status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timedOut || t.status != 0 {
+		return
+	}
+	t.status = status
+}
+
+// Write implements the http.ResponseWriter interface
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	return t.buf.Write(b)
+}
+
+// Written returns true, if the header has been written
+func (t *timeoutWriter) Written() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status != 0
+}
+
+// metaSize returns a size metadata value in bytes
+func metaSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(v))
+		multiplier := int64(1)
+		for _, unit := range []struct {
+			suffix string
+			size   int64
+		}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+			if strings.HasSuffix(s, unit.suffix) {
+				s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+				break
+			}
+		}
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n * multiplier
+	}
+	return 0
+}
+
+// metaDuration returns a duration metadata value
+func metaDuration(v interface{}) time.Duration {
+	switch v := v.(type) {
+	case time.Duration:
+		return v
+	case string:
+		d, _ := time.ParseDuration(v)
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package wrouter
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tUploadController struct {
+	_ Meta `action:"Post_LargeAction" limits.body:"1KB"`
+	_ Meta `action:"SlowAction" limits.timeout:"20ms"`
+}
+
+func (t *tUploadController) Post_SmallAction(w http.ResponseWriter, h *http.Request) {
+	if _, err := io.ReadAll(h.Body); err == nil {
+		w.Write([]byte("ok"))
+	}
+}
+
+func (t *tUploadController) Post_LargeAction(w http.ResponseWriter, h *http.Request) {
+	b, _ := io.ReadAll(h.Body)
+	w.Write([]byte(strings.Repeat("+", len(b)/100)))
+}
+
+func (t *tUploadController) SlowAction(w http.ResponseWriter, h *http.Request) {
+	select {
+	case <-h.Context().Done():
+	case <-time.After(time.Second):
+	}
+	w.Write([]byte("late"))
+}
+
+func (t *tUploadController) FastAction(w http.ResponseWriter) {
+	w.Header().Set("X-Fast", "yes")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("fast"))
+}
+
+func TestBodyLimit(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.Limits.MaxBodySize = 100
+	rt.AddController(&tUploadController{})
+
+	cases := []struct {
+		uri    string
+		size   int
+		chunk  bool
+		status int
+		body   string
+	}{
+		{"/tupload/small", 100, false, 200, "ok"},
+		{"/tupload/small", 101, false, 413, "Request Entity Too Large"},
+		{"/tupload/small", 101, true, 413, "Request Entity Too Large"},
+		{"/tupload/large", 1000, false, 200, "++++++++++"},
+		{"/tupload/large", 1025, false, 413, "Request Entity Too Large"},
+	}
+	// The limits apply with and without the buffered response of a handler timeout
+	for _, timeout := range []time.Duration{0, time.Second} {
+		rt.Configuration.Limits.Timeout = timeout
+		for _, c := range cases {
+			var body io.Reader = strings.NewReader(strings.Repeat("x", c.size))
+			if c.chunk {
+				// hide the length of the body
+				body = io.MultiReader(body)
+			}
+			request := httptest.NewRequest("POST", c.uri, body)
+			if c.chunk {
+				request.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, request)
+			if rec.Code != c.status || rec.Body.String() != c.body {
+				t.Errorf("%s with %d bytes and timeout %s: expected %d %q, got %d %q", c.uri, c.size, timeout,
+					c.status, c.body, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+type tDraftController struct {
+	finished chan struct{}
+}
+
+func (t *tDraftController) SaveAction(h *http.Request, s *Session) {
+	defer close(t.finished)
+	s.Set("draft", 1)
+	<-h.Context().Done()
+	for i := 2; i < 100; i++ {
+		s.Set("draft", i)
+	}
+}
+
+func TestTimeoutSession(t *testing.T) {
+	controller := &tDraftController{finished: make(chan struct{})}
+	rt := NewRouter()
+	rt.SessionStore = NewMemoryStore()
+	rt.Configuration.Limits.Timeout = 10 * time.Millisecond
+	rt.AddController(controller)
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/tdraft/save", nil))
+	<-controller.finished
+	if rec.Code != 503 || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Expected 503 without session, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+type tLateController struct {
+	finished chan struct{}
+}
+
+func (t *tLateController) Post_ReadAction(h *http.Request) {
+	defer close(t.finished)
+	<-h.Context().Done()
+	io.ReadAll(h.Body)
+}
+
+func (t *tLateController) PanicAction(h *http.Request) {
+	defer close(t.finished)
+	<-h.Context().Done()
+	panic("late")
+}
+
+// tLogBuffer collects the output of the standard logger written by other goroutines
+type tLogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *tLogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *tLogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTimeoutLateAction(t *testing.T) {
+	buf := new(tLogBuffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	controller := &tLateController{}
+	rt := NewRouter()
+	rt.Configuration.Limits.Timeout = 10 * time.Millisecond
+	rt.Configuration.Limits.MaxBodySize = 10
+	rt.AddController(controller)
+
+	for _, request := range []*http.Request{
+		httptest.NewRequest("POST", "/tlate/read", io.MultiReader(strings.NewReader(strings.Repeat("x", 100)))),
+		httptest.NewRequest("GET", "/tlate/panic", nil),
+	} {
+		controller.finished = make(chan struct{})
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		<-controller.finished
+		if rec.Code != 503 {
+			t.Errorf("%s: expected 503, got %d", request.URL, rec.Code)
+		}
+	}
+
+	// The panic is logged once the goroutine has recovered
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "panic serving GET /tlate/panic after the timeout: late") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected late panic to be logged, got %q", buf.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.Limits.TimeoutStatus = http.StatusGatewayTimeout
+	rt.AddController(&tUploadController{})
+	rt.Group("/grouped").Timeout(time.Second).AddController(&tUploadController{})
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/tupload/slow", nil))
+	if rec.Code != 504 || rec.Body.String() != "Gateway Timeout" {
+		t.Errorf("Expected 504, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/grouped/tupload/fast", nil))
+	if rec.Code != 201 || rec.Body.String() != "fast" || rec.Header().Get("X-Fast") != "yes" {
+		t.Errorf("Expected buffered response to be sent, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
@@ -0,0 +1,276 @@
+package wrouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metadata contains arbitrary information attached to a route, e.g. "requires auth" or "deprecated since v3".
+// It is available to middlewares by RouteFromContext, to events and injectors by their contexts, and is part
+// of the exported route table. See: Route.Metadata
+type Metadata map[string]interface{}
+
+// MetaSkipFilters is the metadata key of the filters skipped on a route. Its value is a []string, whose
+// entries name the skipped filters. See: FilterContext
+const MetaSkipFilters = "filters.skip"
+
+// MetadataProvider can be implemented by controllers to attach metadata to the routes of their actions.
+// The keys of the returned map are the names of the action methods, e.g. "Post_IndexAction". Metadata
+// stored under "*" applies to all actions of the controller; action specific metadata takes precedence.
+type MetadataProvider interface {
+	RouteMetadata() map[string]Metadata
+}
+
+// Meta is a marker type to declare metadata by struct tags on a controller. Every field of type Meta adds its
+// tags as string metadata to the action named by the "action" tag, or to all actions if the tag is missing or
+// "*". Metadata declared by a MetadataProvider takes precedence. Example:
+//
+//	type UserController struct {
+//		_ wrouter.Meta `action:"Delete_UserAction" auth:"admin" deprecated:"v3"`
+//	}
+type Meta struct{}
+
+var metaType = reflect.TypeOf(Meta{})
+
+// Get returns the value stored under key and whether it exists
+func (m Metadata) Get(key string) (interface{}, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// String returns the value stored under key as string. Values of other types are formatted by fmt.
+func (m Metadata) String(key string) string {
+	v, ok := m[key]
+	if !ok {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Bool returns the value stored under key as bool. Strings are parsed by strconv.ParseBool.
+func (m Metadata) Bool(key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// clone returns a copy of the metadata, which can be changed independently
+func (m Metadata) clone() Metadata {
+	if m == nil {
+		return nil
+	}
+	c := make(Metadata, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
+
+// SetMeta stores a metadata value on the route. It returns the route to allow chaining.
+func (r *Route) SetMeta(key string, value interface{}) *Route {
+	if r.Metadata == nil {
+		r.Metadata = make(Metadata)
+	}
+	r.Metadata[key] = value
+	return r
+}
+
+// Meta returns the metadata value stored under key, or nil
+func (r *Route) Meta(key string) interface{} {
+	return r.Metadata[key]
+}
+
+// WithMeta returns a copy of the group, which adds the given metadata to every route added through it. Keys
+// already set on a route are not overwritten.
+func (g *Group) WithMeta(key string, value interface{}) *Group {
+	g2 := *g
+	g2.metadata = make(Metadata)
+	for k, v := range g.metadata {
+		g2.metadata[k] = v
+	}
+	g2.metadata[key] = value
+	return &g2
+}
+
+// controllerMetadata returns the metadata a controller declares for the given action, by struct tags and by
+// implementing MetadataProvider
+func controllerMetadata(controller Controller, action string) Metadata {
+	meta := make(Metadata)
+	tagMetadata(controller, action, meta)
+
+	if provider, ok := controller.(MetadataProvider); ok {
+		table := provider.RouteMetadata()
+		for key, value := range table["*"] {
+			meta[key] = value
+		}
+		for key, value := range table[action] {
+			meta[key] = value
+		}
+	}
+
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// tagMetadata adds the metadata declared by Meta fields of the controller to meta. Fields for all actions are
+// applied before fields for the given action.
+func tagMetadata(controller Controller, action string, meta Metadata) {
+	rt := reflect.TypeOf(controller)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+		return
+	}
+	rt = rt.Elem()
+
+	var specific []map[string]string
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Type != metaType {
+			continue
+		}
+		tags := parseStructTag(field.Tag)
+		target := tags["action"]
+		delete(tags, "action")
+		switch target {
+		case "", "*":
+			for key, value := range tags {
+				meta[key] = value
+			}
+		case action:
+			specific = append(specific, tags)
+		}
+	}
+
+	for _, tags := range specific {
+		for key, value := range tags {
+			meta[key] = value
+		}
+	}
+}
+
+// parseStructTag parses all key:"value" pairs of a struct tag, following the conventions of reflect.StructTag
+func parseStructTag(tag reflect.StructTag) map[string]string {
+	pairs := make(map[string]string)
+	s := string(tag)
+	for s != "" {
+		s = strings.TrimLeft(s, " ")
+		i := strings.Index(s, ":\"")
+		if i <= 0 || strings.ContainsAny(s[:i], " \"") {
+			break
+		}
+		key := s[:i]
+		s = s[i+1:]
+
+		// find the closing quote, skipping escaped characters
+		j := 1
+		for j < len(s) && s[j] != '"' {
+			if s[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(s) {
+			break
+		}
+		value, err := strconv.Unquote(s[:j+1])
+		if err != nil {
+			break
+		}
+		pairs[key] = value
+		s = s[j+1:]
+	}
+	return pairs
+}
+
+// Routes returns all routes of the router in the order they have been added
+func (r *Router) Routes() []*Route {
+	routes := make([]*Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+// routeExport is the exported representation of a route
+type routeExport struct {
+	Methods  []string               `json:"methods"`
+	Path     string                 `json:"path"`
+	Host     string                 `json:"host,omitempty"`
+	Prefix   bool                   `json:"prefix,omitempty"`
+	Action   string                 `json:"action"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ExportRoutes writes the route table including the metadata of every route as JSON to the io.Writer, e.g.
+// for audits or documentation. Metadata values which cannot be represented in JSON are exported by their
+// fmt representation.
+func (r *Router) ExportRoutes(w io.Writer) error {
+	export := make([]routeExport, 0, len(r.routes))
+	for _, route := range r.routes {
+		re := routeExport{
+			Methods: route.Methods,
+			Path:    "/" + route.Path,
+			Host:    route.Host,
+			Prefix:  route.Prefix,
+			Action:  route.actionName(),
+		}
+		if len(route.Metadata) != 0 {
+			re.Metadata = make(map[string]interface{})
+			for key, value := range route.Metadata {
+				re.Metadata[key] = exportValue(value)
+			}
+		}
+		export = append(export, re)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// exportValue returns a JSON compatible representation of a metadata value
+func exportValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []Policy:
+		policies := make([]string, len(v))
+		for i, p := range v {
+			policies[i] = p.String()
+		}
+		return policies
+	case json.Marshaler:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+// formatMetadata returns the metadata as sorted key=value list, as used by PrintRoutes
+func formatMetadata(m Metadata) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+fmt.Sprint(exportValue(m[key])))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,93 @@
+package wrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type tArticleController struct {
+	_ Meta `summary:"Articles" auth:"none"`
+	_ Meta `action:"Delete_ArticleAction" auth:"admin" deprecated:"v3"`
+}
+
+func (t *tArticleController) ShowAction()           {}
+func (t *tArticleController) Delete_ArticleAction() {}
+
+func (t *tArticleController) RouteMetadata() map[string]Metadata {
+	return map[string]Metadata{
+		"ShowAction": {"ratelimit": 10},
+	}
+}
+
+var tSeenMetadata Metadata
+
+type tMetadataEvent struct{}
+
+func (t *tMetadataEvent) Exec(ctx *PostRequestEventContext) { tSeenMetadata = ctx.Route.Metadata }
+
+func TestRouteMetadata(t *testing.T) {
+	rt := NewRouter()
+	rt.Group("/api").WithMeta("version", "v2").WithMeta("auth", "token").AddController(&tArticleController{})
+	rt.HandleFunc("/health", nil, func(w http.ResponseWriter, r *http.Request) {}).SetMeta("internal", true)
+	rt.AppendPostRequestEvent(new(tMetadataEvent))
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/tarticle/show", nil))
+	if tSeenMetadata.String("summary") != "Articles" || tSeenMetadata["ratelimit"] != 10 ||
+		tSeenMetadata.String("auth") != "none" || tSeenMetadata.String("version") != "v2" {
+		t.Errorf("Unexpected metadata %v", tSeenMetadata)
+	}
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/tarticle/article", nil))
+	if tSeenMetadata.String("auth") != "admin" || tSeenMetadata.String("deprecated") != "v3" {
+		t.Errorf("Unexpected metadata %v", tSeenMetadata)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := rt.ExportRoutes(buf); err != nil {
+		t.Fatal(err)
+	}
+	var export []routeExport
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export) != 3 || export[2].Path != "/health" || export[2].Metadata["internal"] != true ||
+		export[0].Action != "tArticleController.Delete_ArticleAction" {
+		t.Errorf("Unexpected route export %s", buf.String())
+	}
+}
+
+type tHomeController struct {
+	_ Meta `summary:"Home"`
+}
+
+func (t *tHomeController) IndexAction() {}
+
+func TestAliasRouteMetadata(t *testing.T) {
+	rt := NewRouter()
+	rt.Group("/site").Require(RequireRoles("admin")).AddController(&tHomeController{})
+
+	index := rt.findRequestRoute(httptest.NewRequest("GET", "/site/thome/index", nil))
+	alias := rt.findRequestRoute(httptest.NewRequest("GET", "/site/thome", nil))
+	if index == nil || alias == nil || index == alias {
+		t.Fatalf("Expected index and alias route, got %v %v", index, alias)
+	}
+	index.SetMeta("internal", true)
+	if _, ok := alias.Metadata["internal"]; ok || alias.Metadata.String("summary") != "Home" {
+		t.Errorf("Expected independent alias metadata, got %v", alias.Metadata)
+	}
+	for _, route := range []*Route{index, alias} {
+		if policies, _ := route.Metadata[MetaPolicies].([]Policy); len(policies) != 1 {
+			t.Errorf("Expected one group policy on %s, got %d", route.Path, len(policies))
+		}
+	}
+}
+
+func TestParseStructTag(t *testing.T) {
+	tags := parseStructTag(`action:"IndexAction" summary:"Say \"hi\"" empty:""`)
+	if len(tags) != 3 || tags["action"] != "IndexAction" || tags["summary"] != "Say \"hi\"" || tags["empty"] != "" {
+		t.Errorf("Unexpected parsed tags %v", tags)
+	}
+}
@@ -0,0 +1,184 @@
+package wrouter
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets are the default upper bounds (in seconds) of the request latency histogram.
+// See Configuration.Metrics
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricsKey identifies one time series. Routes are labeled by their Path instead of the raw URI, to keep
+// the amount of time series bounded by the amount of routes.
+type metricsKey struct {
+	path   string
+	method string
+}
+
+type routeMetrics struct {
+	requests uint64
+	errors   uint64
+	counts   []uint64
+	sum      float64
+}
+
+// metrics collects per-route request metrics, which are exposed in the Prometheus text exposition format.
+type metrics struct {
+	mu        sync.Mutex
+	buckets   []float64
+	routes    map[metricsKey]*routeMetrics
+	unmatched uint64
+}
+
+func newMetrics() *metrics {
+	m := new(metrics)
+	m.routes = make(map[metricsKey]*routeMetrics)
+	return m
+}
+
+// observe records one request. A request is counted as an error if the status code is 500 or above.
+func (m *metrics) observe(cfg *Configuration, route *Route, method string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if route == nil {
+		m.unmatched++
+		return
+	}
+
+	// The buckets are taken from the configuration on the first observation
+	if m.buckets == nil {
+		buckets := cfg.Metrics.Buckets
+		if len(buckets) == 0 {
+			buckets = DefaultMetricsBuckets
+		}
+		// The buckets are sorted without changing the configuration
+		m.buckets = append([]float64{}, buckets...)
+		sort.Float64s(m.buckets)
+	}
+
+	key := metricsKey{path: "/" + route.Path, method: method}
+	rm, exists := m.routes[key]
+	if !exists {
+		rm = &routeMetrics{counts: make([]uint64, len(m.buckets))}
+		m.routes[key] = rm
+	}
+
+	rm.requests++
+	if status >= http.StatusInternalServerError {
+		rm.errors++
+	}
+
+	seconds := d.Seconds()
+	rm.sum += seconds
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			rm.counts[i]++
+		}
+	}
+}
+
+// write writes all collected metrics in the Prometheus text exposition format to the given io.Writer.
+// If the router uses the default RequestResolver, the route cache statistics are written as well.
+func (m *metrics) write(w io.Writer, rr RequestResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]metricsKey, 0, len(m.routes))
+	for key := range m.routes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path == keys[j].path {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].path < keys[j].path
+	})
+
+	var b strings.Builder
+
+	writeMetricHeader(&b, "wrouter_requests_total", "counter", "Total number of requests per route.")
+	for _, key := range keys {
+		b.WriteString("wrouter_requests_total" + key.labels("") + " " + formatUint(m.routes[key].requests) + "\n")
+	}
+
+	writeMetricHeader(&b, "wrouter_request_errors_total", "counter",
+		"Total number of requests per route answered with a status code of 500 or above.")
+	for _, key := range keys {
+		b.WriteString("wrouter_request_errors_total" + key.labels("") + " " + formatUint(m.routes[key].errors) + "\n")
+	}
+
+	writeMetricHeader(&b, "wrouter_request_duration_seconds", "histogram", "Request latency per route.")
+	for _, key := range keys {
+		rm := m.routes[key]
+		for i, upper := range m.buckets {
+			b.WriteString("wrouter_request_duration_seconds_bucket" + key.labels(formatFloat(upper)) + " " +
+				formatUint(rm.counts[i]) + "\n")
+		}
+		b.WriteString("wrouter_request_duration_seconds_bucket" + key.labels("+Inf") + " " +
+			formatUint(rm.requests) + "\n")
+		b.WriteString("wrouter_request_duration_seconds_sum" + key.labels("") + " " + formatFloat(rm.sum) + "\n")
+		b.WriteString("wrouter_request_duration_seconds_count" + key.labels("") + " " +
+			formatUint(rm.requests) + "\n")
+	}
+
+	writeMetricHeader(&b, "wrouter_unmatched_requests_total", "counter",
+		"Total number of requests which did not match any route.")
+	b.WriteString("wrouter_unmatched_requests_total " + formatUint(m.unmatched) + "\n")
+
+	if rq, ok := rr.(*rqResolver); ok {
+		hits, misses := rq.cache.stats()
+		ratio := 0.0
+		if hits+misses != 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+		writeMetricHeader(&b, "wrouter_route_cache_hits_total", "counter", "Total number of route cache hits.")
+		b.WriteString("wrouter_route_cache_hits_total " + formatUint(hits) + "\n")
+		writeMetricHeader(&b, "wrouter_route_cache_misses_total", "counter", "Total number of route cache misses.")
+		b.WriteString("wrouter_route_cache_misses_total " + formatUint(misses) + "\n")
+		writeMetricHeader(&b, "wrouter_route_cache_hit_ratio", "gauge", "Ratio of route cache hits to lookups.")
+		b.WriteString("wrouter_route_cache_hit_ratio " + formatFloat(ratio) + "\n")
+	}
+
+	io.WriteString(w, b.String())
+}
+
+// serve answers a request to the metrics path
+func (m *metrics) serve(w http.ResponseWriter, rr RequestResolver) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w, rr)
+}
+
+// labels returns the label set of the key. If le is not empty, it is added as the histogram bucket label.
+func (k metricsKey) labels(le string) string {
+	l := "{route=\"" + escapeLabel(k.path) + "\",method=\"" + escapeLabel(k.method) + "\""
+	if le != "" {
+		l += ",le=\"" + le + "\""
+	}
+	return l + "}"
+}
+
+func writeMetricHeader(b *strings.Builder, name, typ, help string) {
+	b.WriteString("# HELP " + name + " " + help + "\n")
+	b.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func escapeLabel(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return strings.Replace(s, "\n", "\\n", -1)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,53 @@
+package wrouter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+	rt.Configuration.Metrics.Enabled = true
+
+	for i := 0; i < 3; i++ {
+		rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tmock/another", nil))
+	}
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/doesntexist", nil))
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	expected := []string{
+		"wrouter_requests_total{route=\"/tmock/another\",method=\"GET\"} 3",
+		"wrouter_request_errors_total{route=\"/tmock/another\",method=\"GET\"} 0",
+		"wrouter_request_duration_seconds_bucket{route=\"/tmock/another\",method=\"GET\",le=\"+Inf\"} 3",
+		"wrouter_request_duration_seconds_count{route=\"/tmock/another\",method=\"GET\"} 3",
+		"wrouter_unmatched_requests_total 1",
+		"wrouter_route_cache_hits_total 2",
+		"wrouter_route_cache_misses_total 2",
+	}
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", e, body)
+		}
+	}
+}
+
+func TestMetricsBucketsUnchanged(t *testing.T) {
+	buckets := []float64{1, 0.1, 0.5}
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+	rt.Configuration.Metrics.Enabled = true
+	rt.Configuration.Metrics.Buckets = buckets
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tmock/another", nil))
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if buckets[0] != 1 || buckets[1] != 0.1 || !strings.Contains(rec.Body.String(), `le="0.1"} 1`) {
+		t.Errorf("Expected sorted buckets without changing the configuration, got %v:\n%s", buckets,
+			rec.Body.String())
+	}
+}
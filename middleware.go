@@ -0,0 +1,48 @@
+package wrouter
+
+import (
+	"context"
+	"net/http"
+)
+
+// Middleware wraps the dispatching of a request after its route has been resolved. The resolved Route can be
+// retrieved by RouteFromContext; it is nil if no route matched. Middlewares are executed in the order they have
+// been added, after the PreRequestEvents and before the controller action or handler is called.
+type Middleware func(http.Handler) http.Handler
+
+type routeContextKey struct{}
+
+// RouteFromContext returns the Route resolved for the request the context belongs to, or nil if no route matched
+func RouteFromContext(ctx context.Context) *Route {
+	route, _ := ctx.Value(routeContextKey{}).(*Route)
+	return route
+}
+
+// Use appends one or more middlewares to the middleware chain of the router.
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// dispatch runs the middleware chain, which ends in serving the resolved route
+func (r *Router) dispatch(w http.ResponseWriter, h *http.Request, route *Route) {
+	if route != nil {
+		ctx := context.WithValue(h.Context(), routeContextKey{}, route)
+		if route.host != nil {
+			ctx = context.WithValue(ctx, hostParamsContextKey{}, route.hostParams(h.Host))
+		}
+		h = h.WithContext(ctx)
+	}
+
+	if len(r.middleware) == 0 {
+		r.serveRoute(w, h, route)
+		return
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, h *http.Request) {
+		r.serveRoute(w, h, RouteFromContext(h.Context()))
+	})
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	handler.ServeHTTP(w, h)
+}
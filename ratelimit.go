@@ -0,0 +1,328 @@
+package wrouter
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaRateLimit is the metadata key of the rate limit of a route. Its value is a *RateLimit.
+// See: Route.RateLimit, Group.RateLimit
+const MetaRateLimit = "ratelimit"
+
+// RateLimitAlgorithm defines how requests are counted
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to RateLimit.Burst requests, refilled evenly over the window
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows RateLimit.Limit requests within any window, approximated by weighting the count of
+	// the previous window
+	SlidingWindow
+)
+
+// RateLimitKeyFunc returns the key requests are counted by, e.g. the client IP. Requests for which an empty
+// key is returned are not limited.
+type RateLimitKeyFunc func(ctx *InjectorContext) string
+
+// RateLimit defines how many requests a client may send to a route. Exceeding requests are answered with 429
+// Too Many Requests through the error handling. All responses carry the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, 429 responses a Retry-After header.
+type RateLimit struct {
+	// Name identifies the counters of the limit. Routes sharing a rate limit with the same name share their
+	// counters. If empty, every route is counted separately by its host and path, and alias routes are counted
+	// with the route they are an alias of.
+	Name string
+	// Limit is the number of requests allowed per Window
+	Limit int
+	// Window is the duration Limit applies to
+	Window time.Duration
+	// Burst is the capacity of the token bucket. If zero, Limit is used. Only used by TokenBucket.
+	Burst int
+	// Algorithm is the algorithm requests are counted with
+	Algorithm RateLimitAlgorithm
+	// Key returns the key requests are counted by. If nil, KeyByIP without trusted proxies is used.
+	Key RateLimitKeyFunc
+}
+
+// String implements the fmt.Stringer interface, e.g. for the exported route table
+func (l *RateLimit) String() string {
+	return "ratelimit(" + strconv.Itoa(l.Limit) + "/" + l.Window.String() + ")"
+}
+
+func (l *RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Limit
+}
+
+// RateLimit sets the rate limit of the route. It returns the route to allow chaining.
+func (r *Route) RateLimit(limit *RateLimit) *Route {
+	return r.SetMeta(MetaRateLimit, limit)
+}
+
+// RateLimit returns a copy of the group, which sets the rate limit on every route added through it, unless the
+// route has its own limit
+func (g *Group) RateLimit(limit *RateLimit) *Group {
+	return g.WithMeta(MetaRateLimit, limit)
+}
+
+// RateLimitResult is the state of a rate limit counter after a request has been counted
+type RateLimitResult struct {
+	// Allowed is true, if the request is within the limit
+	Allowed bool
+	// Limit is the maximum number of requests
+	Limit int
+	// Remaining is the number of requests left
+	Remaining int
+	// Reset is the time until the counter is fully reset
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, if the request has been rejected
+	RetryAfter time.Duration
+}
+
+// RateLimitStore counts requests. Implementations for shared backends allow limiting requests across multiple
+// processes. See: MemoryRateLimitStore
+type RateLimitStore interface {
+	// Take counts one request for the key and returns the resulting state
+	Take(key string, limit *RateLimit) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is a RateLimitStore keeping the counters in memory. Idle counters are removed
+// periodically.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	counters  map[string]*rateCounter
+	lastSweep time.Time
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// rateCounter is the state of a token bucket or a sliding window
+type rateCounter struct {
+	// tokens and last are used by TokenBucket
+	tokens float64
+	last   time.Time
+	// start, current and previous are used by SlidingWindow
+	start    time.Time
+	current  int
+	previous int
+
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: make(map[string]*rateCounter), lastSweep: time.Now(), now: time.Now}
+}
+
+// Take implements the RateLimitStore interface
+func (m *MemoryRateLimitStore) Take(key string, limit *RateLimit) (RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, c := range m.counters {
+			if now.After(c.expires) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	c, ok := m.counters[key]
+	if !ok {
+		c = &rateCounter{tokens: float64(limit.burst()), last: now, start: now}
+		m.counters[key] = c
+	}
+	c.expires = now.Add(2 * limit.Window)
+
+	if limit.Algorithm == SlidingWindow {
+		return c.slidingWindow(limit, now), nil
+	}
+	return c.tokenBucket(limit, now), nil
+}
+
+func (c *rateCounter) tokenBucket(limit *RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.burst())
+	rate := float64(limit.Limit) / limit.Window.Seconds()
+	c.tokens = math.Min(capacity, c.tokens+now.Sub(c.last).Seconds()*rate)
+	c.last = now
+
+	result := RateLimitResult{Limit: limit.burst()}
+	if c.tokens >= 1 {
+		c.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - c.tokens) / rate)
+	}
+	result.Remaining = int(c.tokens)
+	result.Reset = seconds((capacity - c.tokens) / rate)
+	return result
+}
+
+func (c *rateCounter) slidingWindow(limit *RateLimit, now time.Time) RateLimitResult {
+	// Advance the windows
+	if elapsed := now.Sub(c.start); elapsed >= limit.Window {
+		windows := elapsed / limit.Window
+		c.start = c.start.Add(windows * limit.Window)
+		if windows == 1 {
+			c.previous = c.current
+		} else {
+			c.previous = 0
+		}
+		c.current = 0
+	}
+
+	elapsed := now.Sub(c.start)
+	weight := 1 - elapsed.Seconds()/limit.Window.Seconds()
+	count := float64(c.previous)*weight + float64(c.current)
+
+	result := RateLimitResult{Limit: limit.Limit, Reset: limit.Window - elapsed}
+	if count+1 <= float64(limit.Limit) {
+		c.current++
+		count++
+		result.Allowed = true
+	} else if c.current+1 > limit.Limit || c.previous == 0 {
+		// The current window alone exceeds the limit
+		result.RetryAfter = limit.Window - elapsed
+	} else {
+		// Wait until the weight of the previous window has decreased enough
+		needed := 1 - float64(limit.Limit-c.current-1)/float64(c.previous)
+		result.RetryAfter = seconds(needed*limit.Window.Seconds()) - elapsed
+	}
+	result.Remaining = int(math.Max(0, float64(limit.Limit)-math.Ceil(count)))
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// KeyByIP returns a RateLimitKeyFunc counting requests by client IP. If the request is sent by one of the
+// trusted proxies, given as IPs or CIDR ranges, the client IP is taken from the X-Forwarded-For header,
+// skipping all trusted proxies from the right. It panics if a proxy cannot be parsed.
+func KeyByIP(trustedProxies ...string) RateLimitKeyFunc {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(err)
+		}
+		trusted = append(trusted, network)
+	}
+
+	return func(ctx *InjectorContext) string {
+		return "ip:" + ClientIP(ctx.Request, trusted)
+	}
+}
+
+// ClientIP returns the IP of the client which sent the request. If the request is sent by one of the trusted
+// proxies, the client IP is taken from the X-Forwarded-For header.
+func ClientIP(h *http.Request, trusted []*net.IPNet) string {
+	ip := h.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+
+	forwarded := splitList(strings.Join(h.Header.Values("X-Forwarded-For"), ","))
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = forwarded[i]
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyByIdentity returns a RateLimitKeyFunc counting requests by the name of the identity resolved by the
+// Router.IdentityProvider, e.g. InjectorIdentityProvider for identities provided by an injector. Anonymous
+// requests are counted by the given fallback, or not limited if it is nil.
+func KeyByIdentity(fallback RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(ctx *InjectorContext) string {
+		if id := ctx.Router.identity(ctx); id != nil {
+			return "id:" + id.Name()
+		}
+		if fallback != nil {
+			return fallback(ctx)
+		}
+		return ""
+	}
+}
+
+// defaultRateLimitKey counts requests by the remote address
+var defaultRateLimitKey = KeyByIP()
+
+// limitRate counts the request against the rate limit of the route and sets the rate limit headers. If the
+// limit is exceeded, 429 is served and false is returned. Errors of the store do not reject requests.
+func (r *Router) limitRate(w http.ResponseWriter, h *http.Request, route *Route) bool {
+	limit, ok := route.Metadata[MetaRateLimit].(*RateLimit)
+	if !ok || limit.Limit <= 0 || limit.Window <= 0 {
+		return true
+	}
+
+	keyFunc := limit.Key
+	if keyFunc == nil {
+		keyFunc = defaultRateLimitKey
+	}
+	key := keyFunc(createInjectorContext(h, route, r, w))
+	if key == "" {
+		return true
+	}
+	name := limit.Name
+	if name == "" {
+		// Alias routes are counted with the route they are an alias of
+		target := route
+		if route.aliasOf != nil {
+			target = route.aliasOf
+		}
+		name = target.Host + "/" + target.Path
+	}
+
+	result, err := r.RateLimitStore.Take(name+"|"+key, limit)
+	if err != nil {
+		return true
+	}
+
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+	if result.Allowed {
+		return true
+	}
+
+	header.Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(result.RetryAfter.Seconds())))))
+	r.serveError(w, h, http.StatusTooManyRequests)
+	return false
+}
@@ -0,0 +1,117 @@
+package wrouter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	rt := NewRouter()
+	rt.RateLimitStore = store
+	rt.IdentityProvider = new(tIdentityProvider)
+	rt.HandleFunc("/bucket", nil, func(w http.ResponseWriter, r *http.Request) {}).
+		RateLimit(&RateLimit{Limit: 2, Window: time.Minute})
+	rt.Group("/api").RateLimit(&RateLimit{Limit: 3, Window: time.Minute, Algorithm: SlidingWindow,
+		Key: KeyByIdentity(nil)}).HandleFunc("/items", nil, func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(uri, remote, user string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", uri, nil)
+		request.RemoteAddr = remote
+		if user != "" {
+			request.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		return rec
+	}
+
+	do("/bucket", "10.0.0.1:1234", "")
+	if rec := do("/bucket", "10.0.0.1:1234", ""); rec.Code != 200 || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected second request to pass with 0 remaining, got %d %v", rec.Code, rec.Header())
+	}
+	rec := do("/bucket", "10.0.0.1:1234", "")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "30" || rec.Body.String() != "Too Many Requests" {
+		t.Errorf("Expected 429 with Retry-After 30, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := do("/bucket", "10.0.0.2:1234", ""); rec.Code != 200 {
+		t.Errorf("Other clients must not be limited, got %d", rec.Code)
+	}
+	now = now.Add(30 * time.Second)
+	if rec := do("/bucket", "10.0.0.1:1234", ""); rec.Code != 200 {
+		t.Errorf("Expected a refilled token, got %d", rec.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		do("/api/items", "10.0.0.1:1234", "bob")
+	}
+	if rec := do("/api/items", "10.0.0.2:1234", "bob"); rec.Code != 429 {
+		t.Errorf("Expected identity to be limited across IPs, got %d", rec.Code)
+	}
+	if rec := do("/api/items", "10.0.0.1:1234", "alice"); rec.Code != 200 {
+		t.Errorf("Other identities must not be limited, got %d", rec.Code)
+	}
+	if rec := do("/api/items", "10.0.0.1:1234", ""); rec.Code != 200 || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Anonymous requests must not be limited without fallback, got %d", rec.Code)
+	}
+
+	// After one window, two thirds of the previous requests are still weighted in
+	now = now.Add(80 * time.Second)
+	if rec := do("/api/items", "10.0.0.1:1234", "bob"); rec.Code != 200 {
+		t.Errorf("Expected request in next window to pass, got %d", rec.Code)
+	}
+	if rec := do("/api/items", "10.0.0.1:1234", "bob"); rec.Code != 429 {
+		t.Errorf("Expected sliding window to limit, got %d", rec.Code)
+	}
+}
+
+type tInboxController struct{}
+
+func (t *tInboxController) IndexAction() {}
+
+func TestRateLimitAlias(t *testing.T) {
+	rt := NewRouter()
+	limit := &RateLimit{Limit: 1, Window: time.Minute}
+	rt.Group("/").RateLimit(limit).AddController(&tInboxController{})
+	rt.Group("/v1").RateLimit(limit).AddController(&tInboxController{})
+	rt.Group("/v2").RateLimit(limit).AddController(&tInboxController{})
+
+	// Groups are counted separately, alias routes with their route
+	for i, uri := range []string{"/tinbox/index", "/tinbox", "/v1/tinbox/index", "/v2/tinbox", "/v2/tinbox/index"} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+		if expected := []int{200, 429, 200, 200, 429}[i]; rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", uri, expected, rec.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+	cases := []struct {
+		remote, forwarded, ip string
+	}{
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			request.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if ip := ClientIP(request, trusted); ip != c.ip {
+			t.Errorf("%s via %q: expected %s, got %s", c.remote, c.forwarded, c.ip, ip)
+		}
+	}
+}
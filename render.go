@@ -0,0 +1,378 @@
+package wrouter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// MetaFormats is the metadata key of the formats a route offers, e.g. "json" or "html". Its value is a
+// []string or a comma separated string, in order of preference. If missing, all registered formats are
+// offered. See: Route.Formats
+const MetaFormats = "formats"
+
+// UnsupportedValueError indicates that a renderer cannot render the value returned by an action
+var UnsupportedValueError = errors.New("The value cannot be rendered in this format")
+
+// RenderContext contains the value returned by an action and the request it has been returned for
+type RenderContext struct {
+	Request *http.Request
+	Route   *Route
+	// Value is the first value returned by the action, which is neither an error nor a validator
+	Value interface{}
+	// Format is the name of the negotiated format
+	Format string
+}
+
+// Renderer writes values returned by controller actions in one media type. See: Router.AddRenderer
+type Renderer interface {
+	// MediaType returns the media type written by the renderer, e.g. "application/json"
+	MediaType() string
+	// Render writes the value of the context. The Content-Type header is set by the router.
+	Render(w io.Writer, ctx *RenderContext) error
+}
+
+// JSONRenderer renders values by encoding/json
+type JSONRenderer struct {
+	// Indent when set, indents the output by the given string
+	Indent string
+}
+
+// MediaType implements the Renderer interface
+func (j *JSONRenderer) MediaType() string { return "application/json" }
+
+// Render implements the Renderer interface
+func (j *JSONRenderer) Render(w io.Writer, ctx *RenderContext) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", j.Indent)
+	return enc.Encode(ctx.Value)
+}
+
+// XMLRenderer renders values by encoding/xml
+type XMLRenderer struct{}
+
+// MediaType implements the Renderer interface
+func (x *XMLRenderer) MediaType() string { return "application/xml" }
+
+// Render implements the Renderer interface
+func (x *XMLRenderer) Render(w io.Writer, ctx *RenderContext) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(ctx.Value)
+}
+
+// CSVRenderer renders [][]string, and slices of structs with one column per exported field. The header row
+// contains the field names, or the names given by `csv:"name"` tags. Fields tagged `csv:"-"` are omitted.
+type CSVRenderer struct{}
+
+// MediaType implements the Renderer interface
+func (c *CSVRenderer) MediaType() string { return "text/csv" }
+
+// Render implements the Renderer interface
+func (c *CSVRenderer) Render(w io.Writer, ctx *RenderContext) error {
+	cw := csv.NewWriter(w)
+	if records, ok := ctx.Value.([][]string); ok {
+		cw.WriteAll(records)
+		return cw.Error()
+	}
+
+	v := reflect.ValueOf(ctx.Value)
+	if v.Kind() != reflect.Slice {
+		return UnsupportedValueError
+	}
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return UnsupportedValueError
+	}
+
+	var fields []int
+	var header []string
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Field(i)
+		name := field.Tag.Get("csv")
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	cw.Write(header)
+	for i := 0; i < v.Len(); i++ {
+		item := reflect.Indirect(v.Index(i))
+		record := make([]string, len(fields))
+		if item.IsValid() {
+			for j, field := range fields {
+				record[j] = fmt.Sprint(item.Field(field).Interface())
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// HTMLRenderer renders values by executing an html/template with the value as data
+type HTMLRenderer struct {
+	Template *template.Template
+}
+
+// MediaType implements the Renderer interface
+func (h *HTMLRenderer) MediaType() string { return "text/html" }
+
+// Render implements the Renderer interface
+func (h *HTMLRenderer) Render(w io.Writer, ctx *RenderContext) error {
+	if h.Template == nil {
+		return UnsupportedValueError
+	}
+	return h.Template.Execute(w, ctx.Value)
+}
+
+// namedRenderer is a renderer registered for a format
+type namedRenderer struct {
+	format   string
+	renderer Renderer
+}
+
+// AddRenderer registers a renderer for a format name, e.g. "json". Values returned by controller actions are
+// rendered by the renderer negotiated by the Accept header of the request, which can be overridden by the
+// format query parameter or path extension (see Configuration.Rendering). On equal preference, renderers
+// registered first are preferred. Without renderers, returned values are left to the PostRequest events.
+func (r *Router) AddRenderer(format string, renderer Renderer) {
+	r.renderers = append(r.renderers, namedRenderer{format: format, renderer: renderer})
+}
+
+// Formats restricts the formats the route offers. It returns the route to allow chaining.
+func (r *Route) Formats(formats ...string) *Route {
+	return r.SetMeta(MetaFormats, formats)
+}
+
+// Formats returns a copy of the group, which restricts the formats of every route added through it, unless
+// the route has its own restriction
+func (g *Group) Formats(formats ...string) *Group {
+	return g.WithMeta(MetaFormats, formats)
+}
+
+type formatContextKey struct{}
+
+// routeFormats returns the formats offered by a route, or nil if all formats are offered
+func routeFormats(route *Route) []string {
+	switch v := route.Metadata[MetaFormats].(type) {
+	case []string:
+		return v
+	case string:
+		return splitList(v)
+	}
+	return nil
+}
+
+// offeredRenderers returns the renderers offered by the route, in order of preference
+func (r *Router) offeredRenderers(route *Route) []namedRenderer {
+	formats := routeFormats(route)
+	if formats == nil {
+		return r.renderers
+	}
+	offered := make([]namedRenderer, 0, len(formats))
+	for _, format := range formats {
+		for _, nr := range r.renderers {
+			if nr.format == format {
+				offered = append(offered, nr)
+			}
+		}
+	}
+	return offered
+}
+
+// negotiateRenderer selects the renderer for the request. An explicitly requested format takes precedence
+// over the Accept header. It returns nil, if no offered renderer is acceptable.
+func (r *Router) negotiateRenderer(w http.ResponseWriter, h *http.Request, route *Route) *namedRenderer {
+	offered := r.offeredRenderers(route)
+
+	if format := r.requestedFormat(h); format != "" {
+		for i := range offered {
+			if offered[i].format == format {
+				return &offered[i]
+			}
+		}
+		return nil
+	}
+
+	w.Header().Add("Vary", "Accept")
+	accept := h.Header.Get("Accept")
+	if accept == "" && len(offered) != 0 {
+		return &offered[0]
+	}
+
+	var best *namedRenderer
+	bestQ := 0.0
+	for i := range offered {
+		if q := acceptQuality(accept, offered[i].renderer.MediaType()); q > bestQ {
+			best, bestQ = &offered[i], q
+		}
+	}
+	return best
+}
+
+// requestedFormat returns the format explicitly requested by a path extension or the format parameter
+func (r *Router) requestedFormat(h *http.Request) string {
+	format, _ := h.Context().Value(formatContextKey{}).(string)
+	if param := r.Configuration.Rendering.FormatParam; format == "" && param != "" {
+		format = h.URL.Query().Get(param)
+	}
+	return format
+}
+
+// acceptQuality returns the quality of a media type in an Accept header. The most specific matching media
+// range decides, e.g. "text/html;q=0" excludes HTML even if "*/*" is accepted.
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	for _, part := range splitList(accept) {
+		mediaRange, q := parseQuality(part)
+		mediaRange = strings.ToLower(mediaRange)
+		if !matchMediaRange(mediaRange, mediaType) {
+			continue
+		}
+		s := 2
+		if mediaRange == "*/*" {
+			s = 0
+		} else if strings.HasSuffix(mediaRange, "/*") {
+			s = 1
+		}
+		if s > specificity || s == specificity && q > quality {
+			quality, specificity = q, s
+		}
+	}
+	return quality
+}
+
+// matchMediaRange returns true, if the media type is contained in the media range of an Accept header
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return mediaRange == mediaType
+}
+
+// renderValue returns the value to render from the values returned by an action. Errors and validators are
+// not rendered. The second return value is the first non-nil error returned.
+func renderValue(values []reflect.Value) (interface{}, bool, error) {
+	var value interface{}
+	found := false
+	var err error
+	for _, v := range values {
+		if v.Type() == errorType {
+			if !v.IsNil() && err == nil {
+				err = v.Interface().(error)
+			}
+			continue
+		}
+		if v.Type() == etagType || v.Type() == lastModifiedType || isEventChannel(v.Type()) || found {
+			continue
+		}
+		value, found = v.Interface(), true
+	}
+	return value, found, err
+}
+
+var (
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	etagType         = reflect.TypeOf(ETag(""))
+	lastModifiedType = reflect.TypeOf(LastModified{})
+)
+
+// rendersValues returns true, if the values returned by the route's action are rendered by a renderer
+func (r *Router) rendersValues(route *Route) bool {
+	if len(r.renderers) == 0 {
+		return false
+	}
+	for i := 0; i < route.RMethod.Type.NumOut(); i++ {
+		out := route.RMethod.Type.Out(i)
+		if out != errorType && out != etagType && out != lastModifiedType && !isEventChannel(out) {
+			return true
+		}
+	}
+	return false
+}
+
+// render writes the value returned by an action with the negotiated renderer. Actions returning a non-nil
+// error are answered with 500.
+func (r *Router) render(w http.ResponseWriter, h *http.Request, route *Route, renderer *namedRenderer,
+	values []reflect.Value) {
+	value, found, err := renderValue(values)
+	if responseWritten(w) {
+		return
+	}
+	if err != nil {
+		r.serveError(w, h, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		return
+	}
+
+	contentType := renderer.renderer.MediaType()
+	if strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "json") ||
+		strings.HasSuffix(contentType, "xml") {
+		contentType = mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})
+	}
+
+	// The value is rendered into a buffer, so that errors can still be answered with 500
+	buf := new(strings.Builder)
+	ctx := &RenderContext{Request: h, Route: route, Value: value, Format: renderer.format}
+	if err := renderer.renderer.Render(buf, ctx); err != nil {
+		r.serveError(w, h, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	io.WriteString(w, buf.String())
+}
+
+// resolveFormatExtension resolves the route of a request whose last path segment ends in the extension of a
+// registered format, e.g. "/articles/list.json". The format is stored in the request context.
+func (r *Router) resolveFormatExtension(h *http.Request) (*http.Request, *Route) {
+	ext := path.Ext(h.URL.Path)
+	if len(ext) < 2 {
+		return h, nil
+	}
+	format := ext[1:]
+	known := false
+	for _, nr := range r.renderers {
+		known = known || nr.format == format
+	}
+	if !known {
+		return h, nil
+	}
+
+	stripped := *h
+	u := *h.URL
+	u.Path = strings.TrimSuffix(u.Path, ext)
+	if u.RawPath != "" {
+		u.RawPath = strings.TrimSuffix(u.RawPath, ext)
+	}
+	stripped.URL = &u
+	route := r.findRequestRoute(&stripped)
+	if route == nil {
+		return h, nil
+	}
+	return stripped.WithContext(context.WithValue(h.Context(), formatContextKey{}, format)), route
+}
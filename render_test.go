@@ -0,0 +1,109 @@
+package wrouter
+
+import (
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type tBook struct {
+	Title  string `json:"title" xml:"title" csv:"title"`
+	Pages  int    `json:"pages" xml:"pages" csv:"pages"`
+	secret string
+}
+
+type tCatalogController struct {
+	_ Meta `action:"ExportAction" formats:"csv, json"`
+}
+
+func (t *tCatalogController) ListAction() []tBook {
+	return []tBook{{"Go", 300, ""}, {"Web", 120, ""}}
+}
+
+func (t *tCatalogController) ExportAction() []tBook {
+	return t.ListAction()
+}
+
+func (t *tCatalogController) FailAction() (*tBook, error) {
+	return nil, errors.New("failed")
+}
+
+func TestContentNegotiation(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.Rendering.PathExtensions = true
+	rt.AddRenderer("json", new(JSONRenderer))
+	rt.AddRenderer("xml", new(XMLRenderer))
+	rt.AddRenderer("csv", new(CSVRenderer))
+	rt.AddRenderer("html", &HTMLRenderer{Template: template.Must(template.New("list").Parse(
+		`{{range .}}<li>{{.Title}}</li>{{end}}`))})
+	rt.AddController(&tCatalogController{})
+
+	jsonBody := `[{"title":"Go","pages":300},{"title":"Web","pages":120}]` + "\n"
+	csvBody := "title,pages\nGo,300\nWeb,120\n"
+	cases := []struct {
+		uri, accept string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/tcatalog/list", "", 200, "application/json; charset=utf-8", jsonBody},
+		{"/tcatalog/list", "text/html,application/xhtml+xml,*/*;q=0.8", 200, "text/html; charset=utf-8",
+			"<li>Go</li><li>Web</li>"},
+		{"/tcatalog/list", "application/json;q=0.5, application/xml", 200, "application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				"<tBook><title>Go</title><pages>300</pages></tBook><tBook><title>Web</title><pages>120</pages></tBook>"},
+		{"/tcatalog/list", "text/*, text/html;q=0", 200, "text/csv; charset=utf-8", csvBody},
+		{"/tcatalog/list?format=csv", "application/json", 200, "text/csv; charset=utf-8", csvBody},
+		{"/tcatalog/list.csv", "application/json", 200, "text/csv; charset=utf-8", csvBody},
+		{"/tcatalog/list", "image/png", 406, "text/plain; charset=utf-8", "Not Acceptable"},
+		{"/tcatalog/export", "text/html", 406, "text/plain; charset=utf-8", "Not Acceptable"},
+		{"/tcatalog/export.xml", "", 406, "text/plain; charset=utf-8", "Not Acceptable"},
+		{"/tcatalog/export", "*/*", 200, "text/csv; charset=utf-8", csvBody},
+		{"/tcatalog/fail", "", 500, "text/plain; charset=utf-8", "Internal Server Error"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.uri, nil)
+		if c.accept != "" {
+			request.Header.Set("Accept", c.accept)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Code != c.status || rec.Header().Get("Content-Type") != c.contentType || rec.Body.String() != c.body {
+			t.Errorf("%s with %q: expected %d %q %q, got %d %q %q", c.uri, c.accept, c.status, c.contentType,
+				c.body, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+}
+
+func TestContentNegotiationCached(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.Rendering.PathExtensions = true
+	rt.AddRenderer("json", new(JSONRenderer))
+	rt.AddRenderer("csv", new(CSVRenderer))
+	rt.Group("/").Cache(&CachePolicy{TTL: time.Minute}).AddController(&tCatalogController{})
+
+	csvBody := "title,pages\nGo,300\nWeb,120\n"
+	cases := []struct {
+		uri, accept, body, state string
+	}{
+		{"/tcatalog/list", "application/json", `[{"title":"Go","pages":300},{"title":"Web","pages":120}]` + "\n",
+			"MISS"},
+		{"/tcatalog/list", "text/csv", csvBody, "MISS"},
+		{"/tcatalog/list.csv", "application/json", csvBody, "MISS"},
+		{"/tcatalog/list?format=csv", "application/json", csvBody, "MISS"},
+		{"/tcatalog/list", "text/csv", csvBody, "HIT"},
+		{"/tcatalog/list.csv", "application/json", csvBody, "HIT"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", c.uri, nil)
+		request.Header.Set("Accept", c.accept)
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		if rec.Body.String() != c.body || rec.Header().Get("X-Cache") != c.state {
+			t.Errorf("%s with %q: expected %s %q, got %s %q", c.uri, c.accept, c.state, c.body,
+				rec.Header().Get("X-Cache"), rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package wrouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestID is the identifier of the current request. It can be injected into controller actions by declaring
+// an argument of type wrouter.RequestID. See Configuration.RequestID
+type RequestID string
+
+// maxRequestIDLength is the maximum length of an incoming request ID. Longer IDs are replaced.
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// RequestIDFromContext returns the request ID stored in the context of a request, or an empty string if
+// request IDs are disabled.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(RequestID)
+	return string(id)
+}
+
+// generateRequestID is the default request ID generator, creating 16 random bytes in hex representation
+func generateRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// assignRequestID takes the request ID from the configured header, or generates a new one. The ID is set
+// on the response and stored in the request context.
+func (r *Router) assignRequestID(w http.ResponseWriter, h *http.Request) (*http.Request, RequestID) {
+	cfg := r.Configuration.RequestID
+	id := h.Header.Get(cfg.Header)
+	if !validRequestID(id) {
+		if cfg.Generator != nil {
+			id = cfg.Generator()
+		} else {
+			id = generateRequestID()
+		}
+	}
+
+	w.Header().Set(cfg.Header, id)
+	return h.WithContext(context.WithValue(h.Context(), requestIDContextKey{}, RequestID(id))), RequestID(id)
+}
+
+// validRequestID only accepts printable ASCII of a limited length, so that incoming IDs cannot be used to
+// inject content into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e || id[i] == '"' || id[i] == '\\' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package wrouter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type tRequestIDController struct{}
+
+var tInjectedRequestID RequestID
+
+func (t *tRequestIDController) IndexAction(id RequestID) { tInjectedRequestID = id }
+
+func TestRequestID(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tRequestIDController{})
+	rt.Configuration.RequestID.Enabled = true
+	rt.Configuration.AccessLog.Enabled = true
+	buf := new(bytes.Buffer)
+	rt.Configuration.AccessLog.Writer = buf
+
+	request := httptest.NewRequest("GET", "/trequestid/index", nil)
+	request.Header.Set("X-Request-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+
+	if rec.Header().Get("X-Request-ID") != "abc-123" || tInjectedRequestID != "abc-123" {
+		t.Errorf("Incoming request ID was not propagated, got %q and %q", rec.Header().Get("X-Request-ID"),
+			tInjectedRequestID)
+	}
+	if !strings.Contains(buf.String(), "\"abc-123\"") {
+		t.Errorf("Request ID missing in access log: %s", buf.String())
+	}
+
+	// Invalid incoming IDs are replaced by generated ones
+	request = httptest.NewRequest("GET", "/trequestid/index", nil)
+	request.Header.Set("X-Request-ID", "abc\" 123")
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, request)
+
+	generated := rec.Header().Get("X-Request-ID")
+	if len(generated) != 32 || RequestID(generated) != tInjectedRequestID {
+		t.Errorf("Expected generated request ID, got %q", generated)
+	}
+
+	rt.Configuration.RequestID.Header = "X-Correlation-ID"
+	rt.Configuration.RequestID.Generator = func() string { return "generated" }
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/trequestid/index", nil))
+	if rec.Header().Get("X-Correlation-ID") != "generated" || rec.Code != http.StatusOK {
+		t.Errorf("Custom header or generator not used")
+	}
+}
@@ -19,6 +19,33 @@ var WrongTypeError = errors.New("A constroller must be a pointer to a struct")
 // WrongNameError indicates that the controller cannot be resolved, because it doesn't end in Controller
 var WrongNameError = errors.New("A constroller struct name must end in \"Controller\"")
 
+// isHookMethod returns true, if the method is called by the router as a hook or filter of the controller, and
+// therefore never resolved as action. Methods only sharing the name of a hook, e.g. a Before action with
+// different arguments, are actions.
+func isHookMethod(controller Controller, name string) bool {
+	switch name {
+	case "BeforeAction":
+		_, ok := controller.(BeforeActionHook)
+		return ok
+	case "AfterAction":
+		_, ok := controller.(AfterActionHook)
+		return ok
+	case "Before":
+		_, ok := controller.(BeforeFilter)
+		return ok
+	case "After":
+		_, ok := controller.(AfterFilter)
+		return ok
+	case "Around":
+		_, ok := controller.(AroundFilter)
+		return ok
+	case "RouteMetadata":
+		_, ok := controller.(MetadataProvider)
+		return ok
+	}
+	return false
+}
+
 // cleanSlahes is a regex used to replace multiple slashes with one slash in a path. It is pre-compiled
 // for performance reasons.
 var cleanSlashes, _ = regexp.Compile(`\/+`)
@@ -42,7 +69,7 @@ func newRouteResolver(cfg *Configuration) *ctrRouteResolver {
 // Resolve implements the RouteResolver interface
 func (rs *ctrRouteResolver) Resolve(controller Controller) ([]*Route, error) {
 	rct := rs.createControllerReflection(controller)
-	routes := rs.getRoutes(controller, rct, "")
+	routes := rs.getRoutes([]Controller{controller}, nil, rct, "")
 	return routes, nil
 }
 
@@ -67,7 +94,11 @@ func (rs *ctrRouteResolver) verifyController(rct ReflectController) error {
 	return nil
 }
 
-func (rs *ctrRouteResolver) getRoutes(controller Controller, rct ReflectController, prefix string) []*Route {
+// getRoutes creates the routes of the last controller in chain. The chain contains the registered controller
+// and all sub-controllers leading to the current one, fields contains the index of each sub-controller field.
+func (rs *ctrRouteResolver) getRoutes(chain []Controller, fields []int, rct ReflectController, prefix string) []*Route {
+	controller := chain[len(chain)-1]
+
 	// verification of the controller is needed. If the verification doesn't pass, the execution
 	// will terminate and the application will panic. The reason for such strict handling is,
 	// that such error usually occurs when building the application and not later on runtime.
@@ -80,25 +111,35 @@ func (rs *ctrRouteResolver) getRoutes(controller Controller, rct ReflectControll
 
 	// Iterate over all methods of the controller and create routes for it
 	for i := 0; i < rct.NumMethod(); i++ {
+		// Hooks are not actions
+		if isHookMethod(controller, rct.Method(i).Name) {
+			continue
+		}
+
 		// Create routes for the given method. This will typically be one route by the
 		// naming convention, and possibly an index route.
 		rt := rs.createRoutesByMethod(controller, rct.Method(i), rct, prefix)
 		for i := 0; i < len(rt); i++ {
+			rt[i].chain = chain
+			rt[i].fields = fields
 			routes = append(routes, rt[i])
 		}
 	}
 
 	// Add sub-controllers if allowed by configuration
 	if rs.configuration.AllowSubController {
-		subControllers := rs.getSubControllers(rct)
+		subControllers, subFields := rs.getSubControllers(controller, rct)
 		if len(subControllers) != 0 {
 
 			// Create a new prefix for the sub-controller. Because of the recursive nature of the procedure
 			// this is typically the current prefix, and the parent controller path.
 			newPrefix := prefix + controllerPath(controller) + "/"
-			for _, subController := range subControllers {
+			for i, subController := range subControllers {
+				subChain := append(append([]Controller{}, chain...), subController)
+				subFieldChain := append(append([]int{}, fields...), subFields[i])
+
 				// Get subroutes
-				sRoutes := rs.getRoutes(subController, rs.createControllerReflection(subController),
+				sRoutes := rs.getRoutes(subChain, subFieldChain, rs.createControllerReflection(subController),
 					newPrefix)
 
 				// append subroutes to route collection
@@ -120,6 +161,7 @@ func (rs *ctrRouteResolver) createRoutesByMethod(controller Controller, rfm refl
 	route := new(Route)
 	route.Controller = controller
 	route.RMethod = rfm
+	route.Metadata = controllerMetadata(controller, rfm.Name)
 
 	methodName := strings.ToLower(rfm.Name)
 
@@ -145,12 +187,16 @@ func (rs *ctrRouteResolver) createRoutesByMethod(controller Controller, rfm refl
 
 	route.Path = prefix + strings.Replace(strings.ToLower(rct.Elem().Name()), "controller", "", -1) +
 		"/" + methodName
+	route.actionPath = route.Path
 	routes := []*Route{route}
 	if rs.configuration.CreateAliasRoutes && strings.Contains(route.Path, "index") {
 		aliasRoute := new(Route)
 		aliasRoute.Methods = route.Methods
 		aliasRoute.Controller = route.Controller
 		aliasRoute.RMethod = route.RMethod
+		aliasRoute.Metadata = route.Metadata.clone()
+		aliasRoute.actionPath = route.actionPath
+		aliasRoute.aliasOf = route
 
 		newPath := strings.Replace(route.Path, "index", "", -1)
 		aliasRoute.Path = strings.Trim(cleanSlashes.ReplaceAllString(newPath, "/"), "/")
@@ -160,20 +206,25 @@ func (rs *ctrRouteResolver) createRoutesByMethod(controller Controller, rfm refl
 	return routes
 }
 
-func (rs *ctrRouteResolver) getSubControllers(rct ReflectController) []Controller {
+// getSubControllers returns the sub-controllers embedded into the given controller and the index of their
+// fields. Sub-controllers set on exported fields are used as they are, all others are created and their
+// dependencies are wired from the parent. See: subController
+func (rs *ctrRouteResolver) getSubControllers(controller Controller, rct ReflectController) ([]Controller, []int) {
 	var controllers []Controller
+	var fields []int
 	nFn := rct.Elem().NumField()
 	if nFn == 0 {
-		return controllers
+		return controllers, fields
 	}
 
+	parent := reflect.ValueOf(controller)
 	for i := 0; i < nFn; i++ {
 		subrt := rct.Elem().Field(i).Type
 		err := rs.verifyController(subrt)
 		if err == nil {
-			r := reflect.New(subrt.Elem())
-			controllers = append(controllers, r.Interface())
+			controllers = append(controllers, subController(parent, i).Interface())
+			fields = append(fields, i)
 		}
 	}
-	return controllers
+	return controllers, fields
 }
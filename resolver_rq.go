@@ -2,26 +2,50 @@ package wrouter
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// routeCacheSize is the maximum number of cached routes. Prefix and host routes match paths and hosts chosen by
+// clients, so the cache is bounded.
+const routeCacheSize = 4096
+
+// routeCache caches resolved routes by method and path. It is safe for concurrent use, as it is accessed
+// by every request.
 type routeCache struct {
-	pairs map[string]*Route
+	mu     sync.RWMutex
+	pairs  map[string]*Route
+	hits   uint64
+	misses uint64
 }
 
 func (r *routeCache) get(uri string) *Route {
+	r.mu.RLock()
 	route, exists := r.pairs[uri]
+	r.mu.RUnlock()
 	if exists {
+		atomic.AddUint64(&r.hits, 1)
 		return route
 	}
+	atomic.AddUint64(&r.misses, 1)
 	return nil
 }
 
 func (r *routeCache) push(uri string, rt *Route) {
-	if len(r.pairs) == 0 {
+	r.mu.Lock()
+	if len(r.pairs) == 0 || len(r.pairs) >= routeCacheSize {
+		// A full cache is cleared, the routes still requested are cached again
 		r.pairs = make(map[string]*Route)
 	}
 	r.pairs[uri] = rt
+	r.mu.Unlock()
+}
+
+// stats returns the amount of cache hits and misses
+func (r *routeCache) stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&r.hits), atomic.LoadUint64(&r.misses)
 }
 
 type RequestResolver interface {
@@ -41,38 +65,94 @@ func newRqResolver(router *Router) *rqResolver {
 }
 
 func (r *rqResolver) Resolve(request *http.Request) *Route {
-	uri := strings.ToLower(strings.Trim(request.URL.RequestURI(), "?&/"))
-	uriParts := strings.Split(uri, "/")
-	fullPath := strings.Trim(strings.Split(uri, "?")[0], "/")
-	cachePath := request.Method + "__" + fullPath
+	caseSensitive := r.router.Configuration.CaseSensitiveRouting
+	segments := pathSegments(request.URL)
+	fullPath := strings.Join(segments, "/")
+
+	cacheKey := fullPath
+	if !caseSensitive {
+		cacheKey = strings.ToLower(cacheKey)
+	}
+	cachePath := request.Method + "__" + cacheKey
+	host := ""
+	if r.router.hostRoutes {
+		host = normalizeHost(request.Host)
+		cachePath = host + "__" + cachePath
+	}
 	cachedRoute := r.cache.get(cachePath)
 	if cachedRoute != nil {
 		return cachedRoute
 	}
 
-	path := uriParts[0]
-	if len(uriParts) > 1 {
+	path := fullPath
+	if len(segments) > 2 {
 		// match route
-		path = uriParts[0] + "/" + uriParts[1]
+		path = segments[0] + "/" + segments[1]
 	}
 
+	// Routes matching the path exactly take precedence over prefix routes, of which the longest prefix wins.
+	// Routes bound to a host take precedence over routes with the same path which are not, more specific host
+	// patterns over less specific ones.
+	var match *Route
+	matchScore := 0
 	for _, route := range r.router.routes {
-		if route.Path == path || route.Path == fullPath {
-			// reduce complexity for routes with only one method
-			if len(route.Methods) == 1 && request.Method == route.Methods[0] {
-				r.cache.push(cachePath, route)
-				return route
-			}
+		if !route.hasMethod(request.Method) {
+			continue
+		}
+
+		score := 0
+		if equalPath(route.Path, path, caseSensitive) || equalPath(route.Path, fullPath, caseSensitive) {
+			score = 1 << 30
+		} else if route.matchesPrefix(fullPath, caseSensitive) {
+			score = len(route.Path) + 1
+		} else {
+			continue
+		}
 
-			// iterate methods for routes with multiple methods
-			for _, me := range route.Methods {
-				if me == request.Method {
-					r.cache.push(cachePath, route)
-					return route
-				}
+		if route.host != nil {
+			if !route.matchesHost(host) {
+				continue
 			}
+			score += 1<<29 + route.host.specificity()<<20
+		}
+
+		if score > matchScore {
+			match, matchScore = route, score
 		}
 	}
 
-	return nil
+	if match != nil {
+		r.cache.push(cachePath, match)
+	}
+	return match
+}
+
+// pathSegments splits the escaped path of the URL into unescaped segments. Empty segments are dropped. Escaped
+// slashes stay escaped, so that they cannot be confused with path separators when joining the segments.
+func pathSegments(u *url.URL) []string {
+	escaped := strings.Trim(u.EscapedPath(), "/")
+	if escaped == "" {
+		return []string{""}
+	}
+
+	parts := strings.Split(escaped, "/")
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if segment, err := url.PathUnescape(part); err == nil {
+			part = strings.Replace(segment, "/", "%2F", -1)
+		}
+		segments = append(segments, part)
+	}
+	return segments
+}
+
+// equalPath compares a route path with a request path
+func equalPath(routePath, requestPath string, caseSensitive bool) bool {
+	if caseSensitive {
+		return routePath == requestPath
+	}
+	return strings.EqualFold(routePath, requestPath)
 }
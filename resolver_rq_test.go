@@ -0,0 +1,122 @@
+package wrouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRqResolverPaths(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+	rt.AddInjector(new(tMockUserInjector))
+	rt.HandleFunc("/Files/Show", nil, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) })
+	rt.Mount("/raw", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.URL.RawPath))
+	}))
+
+	serve := func(method, uri string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(method, uri, nil))
+		return rec
+	}
+
+	cases := []struct {
+		uri    string
+		status int
+		body   string
+	}{
+		{"/tmock/another?x=1/2", 200, ""},
+		{"/tmock?redirect=/tmock/another", 200, ""},
+		{"/TMock/Another", 200, ""},
+		{"/files/show/ABC", 200, "/files/show/ABC"},
+		{"/tmock/./another", 200, ""},
+		{"/tmock/tsub/../another", 200, ""},
+		{"//tmock///another", 200, ""},
+		{"/tmock%2Fanother", 404, "Not Found"},
+		{"/raw/a%2Fb/C", 200, "/a/b/C /a%2Fb/C"},
+	}
+	for _, c := range cases {
+		if rec := serve("GET", c.uri); rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("GET %s: expected %d %q, got %d %q", c.uri, c.status, c.body, rec.Code, rec.Body.String())
+		}
+	}
+
+	rt.Configuration.RedirectCleanPath = true
+	if rec := serve("GET", "/tmock/x/../another?a=b"); rec.Code != 301 || rec.Header().Get("Location") != "/tmock/another?a=b" {
+		t.Errorf("Expected 301 to clean path, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := serve("DELETE", "/tmock//tsub/user"); rec.Code != 308 || rec.Header().Get("Location") != "/tmock/tsub/user" {
+		t.Errorf("Expected 308 to clean path, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rt.Configuration.CaseSensitiveRouting = true
+	if rec := serve("GET", "/TMock/Another"); rec.Code != 404 {
+		t.Errorf("Expected 404 for case sensitive routing, got %d", rec.Code)
+	}
+	if rec := serve("GET", "/Files/Show"); rec.Code != 200 {
+		t.Errorf("Expected 200 for case sensitive routing, got %d", rec.Code)
+	}
+}
+
+func TestRouteCacheBounded(t *testing.T) {
+	rt := NewRouter()
+	rt.Mount("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	resolver := rt.RequestResolver.(*rqResolver)
+
+	for i := 0; i < routeCacheSize+10; i++ {
+		rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprintf("/files/%d", i), nil))
+	}
+	if n := len(resolver.cache.pairs); n == 0 || n > routeCacheSize {
+		t.Errorf("Expected at most %d cached routes, got %d", routeCacheSize, n)
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tMockController{})
+	rt.AddInjector(new(tMockUserInjector))
+	rt.Mount("/sub", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) }))
+
+	serve := func(method, uri string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(method, uri, nil))
+		return rec
+	}
+
+	if rec := serve("GET", "/tmock/"); rec.Code != 200 {
+		t.Errorf("Expected lenient policy to serve /tmock/, got %d", rec.Code)
+	}
+
+	rt.Configuration.TrailingSlash = TrailingSlashStrict
+	for _, uri := range []string{"/tmock/", "/tmock/another/", "/sub/"} {
+		if rec := serve("GET", uri); rec.Code != 404 {
+			t.Errorf("Expected strict policy to answer %s with 404, got %d", uri, rec.Code)
+		}
+	}
+	for _, uri := range []string{"/tmock", "/tmock/another", "/sub/dir/"} {
+		if rec := serve("GET", uri); rec.Code != 200 {
+			t.Errorf("Expected strict policy to serve %s, got %d", uri, rec.Code)
+		}
+	}
+
+	rt.Configuration.TrailingSlash = TrailingSlashRedirect
+	if rec := serve("GET", "/tmock/?a=1"); rec.Code != 301 || rec.Header().Get("Location") != "/tmock?a=1" {
+		t.Errorf("Expected 301 to /tmock?a=1, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := serve("POST", "/tmock/index/"); rec.Code != 308 || rec.Header().Get("Location") != "/tmock/index" {
+		t.Errorf("Expected 308 to /tmock/index, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rt.Configuration.CleanPath = false
+	if rec := serve("GET", "//tmock/"); rec.Code != 301 || rec.Header().Get("Location") != "/tmock" {
+		t.Errorf("Expected 301 to /tmock without host, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rt.Configuration.CleanPath = true
+	if rec := serve("GET", "/sub/dir/"); rec.Code != 200 || rec.Body.String() != "/dir/" {
+		t.Errorf("Expected mounted handler to receive /dir/, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve("GET", "/doesntexist/"); rec.Code != 404 {
+		t.Errorf("Expected 404 for unknown path, got %d", rec.Code)
+	}
+}
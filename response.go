@@ -0,0 +1,85 @@
+package wrouter
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps the http.ResponseWriter handed to ServeHTTP, to keep track of the status code and the
+// amount of bytes written during the life-cycle of a request.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+
+	// beforeHeader when set, is called once before the header is written, e.g. to save the session
+	beforeHeader func()
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	if w.beforeHeader != nil {
+		f := w.beforeHeader
+		w.beforeHeader = nil
+		f()
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Status returns the status code sent to the client. If nothing has been written yet, 0 is returned.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the amount of body bytes written to the client
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written returns true, if the header has already been sent to the client
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush implements the http.Flusher interface, if the wrapped writer supports it
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, if the wrapped writer supports it
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("wrouter: the underlying ResponseWriter does not support hijacking")
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. It is used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
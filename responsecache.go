@@ -0,0 +1,497 @@
+package wrouter
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaCache is the metadata key of the response cache policy of a route. Its value is a *CachePolicy.
+// See: Route.Cache, Group.Cache
+const MetaCache = "cache"
+
+// DefaultResponseCacheSize is the size of the default response cache in bytes
+const DefaultResponseCacheSize = 32 << 20
+
+// defaultMaxCachedSize is the maximum body size of a cached response, if CachePolicy.MaxSize is not set
+const defaultMaxCachedSize = 1 << 20
+
+// CachePolicy defines how the responses of a route are cached by the router. Only successful responses to GET
+// requests without Set-Cookie and without Cache-Control "no-store" or "private" are cached, HEAD requests are
+// answered from them but never fill the cache. The
+// cache is consulted after the rate limits, policies and CSRF protection of the route have been applied, but
+// responses depending on the identity of the client must name the identifying header, e.g. Authorization,
+// in VaryHeaders. Only the headers set while serving the route are cached, without those belonging to a single
+// request, e.g. request IDs and rate limit headers.
+type CachePolicy struct {
+	// TTL is the time a response is served from the cache
+	TTL time.Duration
+	// StaleWhileRevalidate is the time after the TTL, during which the stale response is still served while
+	// it is refreshed in the background
+	StaleWhileRevalidate time.Duration
+	// VaryHeaders contains the request headers the cached responses differ by
+	VaryHeaders []string
+	// Query contains the query parameters the cached responses differ by. If nil, all parameters are used.
+	Query []string
+	// Tags are attached to all cached responses of the route. Actions can add tags by Caching.Tag.
+	Tags []string
+	// MaxSize is the maximum body size of a cached response in bytes. Larger responses are not cached.
+	//
+	// Default: 1MB
+	MaxSize int
+}
+
+// String implements the fmt.Stringer interface, e.g. for the exported route table
+func (p *CachePolicy) String() string {
+	s := "cache(" + p.TTL.String()
+	if p.StaleWhileRevalidate > 0 {
+		s += ", swr " + p.StaleWhileRevalidate.String()
+	}
+	return s + ")"
+}
+
+// Cache sets the response cache policy of the route. It returns the route to allow chaining.
+func (r *Route) Cache(policy *CachePolicy) *Route {
+	return r.SetMeta(MetaCache, policy)
+}
+
+// Cache returns a copy of the group, which sets the response cache policy on every route added through it,
+// unless the route has its own policy
+func (g *Group) Cache(policy *CachePolicy) *Group {
+	return g.WithMeta(MetaCache, policy)
+}
+
+// CachedResponse is a response stored in a ResponseCacheStore
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Tags   []string
+	// Created is the time the response has been created
+	Created time.Time
+	// Expires is the end of the TTL
+	Expires time.Time
+	// StaleUntil is the end of the stale-while-revalidate period, after which the response can be removed
+	StaleUntil time.Time
+	// Vary contains the request headers named by the Vary header of the response. If set, the entry only
+	// refers to the variants of the response, which are stored under keys including these headers.
+	Vary []string
+}
+
+// size returns the approximate memory used by the response
+func (c *CachedResponse) size() int {
+	n := len(c.Body)
+	for key, values := range c.Header {
+		n += len(key)
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+// ResponseCacheStore stores cached responses. Implementations for shared backends allow multiple processes to
+// share their cache. See: MemoryResponseCache
+type ResponseCacheStore interface {
+	// Get returns the response stored under key
+	Get(key string) (*CachedResponse, bool)
+	// Set stores a response under key
+	Set(key string, response *CachedResponse)
+	// Invalidate removes all responses carrying one of the tags
+	Invalidate(tags ...string)
+}
+
+// MemoryResponseCache is a ResponseCacheStore keeping the responses in memory. When the size limit is
+// reached, the least recently used responses are evicted.
+type MemoryResponseCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	tags    map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// NewMemoryResponseCache creates a MemoryResponseCache holding responses of up to maxSize bytes in total
+func NewMemoryResponseCache(maxSize int) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Get implements the ResponseCacheStore interface
+func (m *MemoryResponseCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.response.StaleUntil) {
+		m.remove(element)
+		return nil, false
+	}
+	m.lru.MoveToFront(element)
+	return entry.response, true
+}
+
+// Set implements the ResponseCacheStore interface
+func (m *MemoryResponseCache) Set(key string, response *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+	if response.size() > m.maxSize {
+		return
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryCacheEntry{key: key, response: response})
+	m.size += response.size()
+	for _, tag := range response.Tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+
+	for m.size > m.maxSize {
+		m.remove(m.lru.Back())
+	}
+}
+
+// Invalidate implements the ResponseCacheStore interface
+func (m *MemoryResponseCache) Invalidate(tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if element, ok := m.entries[key]; ok {
+				m.remove(element)
+			}
+		}
+		delete(m.tags, tag)
+	}
+}
+
+// Len returns the number of cached responses
+func (m *MemoryResponseCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func (m *MemoryResponseCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryCacheEntry)
+	m.lru.Remove(element)
+	delete(m.entries, entry.key)
+	m.size -= entry.response.size()
+	for _, tag := range entry.response.Tags {
+		if keys := m.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(m.tags, tag)
+			}
+		}
+	}
+}
+
+// InvalidateCache removes all cached responses carrying one of the tags
+func (r *Router) InvalidateCache(tags ...string) {
+	r.ResponseCache.Invalidate(tags...)
+}
+
+type cacheTagsContextKey struct{}
+
+// AddCacheTags attaches tags to the response of the request the context belongs to, if it is cached. See:
+// Caching.Tag
+func AddCacheTags(ctx context.Context, tags ...string) {
+	if t, ok := ctx.Value(cacheTagsContextKey{}).(*[]string); ok {
+		*t = append(*t, tags...)
+	}
+}
+
+// cacheKey returns the key of the cached response of a request, consisting of the route, the path, the
+// requested format, and the query parameters and headers named by the policy
+func (r *Router) cacheKey(h *http.Request, route *Route, policy *CachePolicy) string {
+	var b strings.Builder
+	b.WriteString(route.Host + "|" + route.Path + "|" + h.Host + "|" + h.URL.EscapedPath() + "|" +
+		r.requestedFormat(h) + "?")
+
+	query := h.URL.Query()
+	names := policy.Query
+	if names == nil {
+		names = make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+	}
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	values := url.Values{}
+	for _, name := range names {
+		if v, ok := query[name]; ok {
+			values[name] = v
+		}
+	}
+	b.WriteString(values.Encode())
+
+	return varyKey(b.String(), h, policy.VaryHeaders)
+}
+
+// varyKey appends the values of the named request headers to a cache key
+func varyKey(key string, h *http.Request, names []string) string {
+	for _, name := range names {
+		key += "|" + strings.ToLower(name) + "=" + strings.Join(h.Header.Values(name), ",")
+	}
+	return key
+}
+
+// responseVary returns the request headers named by the Vary header of a response
+func responseVary(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serveCached serves the route from the cache, or serves it and caches the response. Stale responses are
+// served while a single background request refreshes them.
+func (r *Router) serveCached(w http.ResponseWriter, h *http.Request, route *Route, policy *CachePolicy) {
+	// The header set so far by the router, e.g. by compression, is passed to background revalidations
+	pipeline := w.Header().Clone()
+	base := r.cacheKey(h, route, policy)
+	key := base
+	cached, ok := r.ResponseCache.Get(key)
+	if ok && cached.Vary != nil {
+		// The responses differ by the headers named in their Vary header
+		key = varyKey(key, h, cached.Vary)
+		cached, ok = r.ResponseCache.Get(key)
+	}
+	if ok {
+		now := time.Now()
+		if now.Before(cached.Expires) {
+			writeCachedResponse(w, h, cached, "HIT")
+			return
+		}
+		if now.Before(cached.StaleUntil) {
+			writeCachedResponse(w, h, cached, "STALE")
+			if _, running := r.revalidating.LoadOrStore(key, true); !running {
+				background := h.Clone(context.WithoutCancel(h.Context()))
+				background.Method = "GET"
+				go func() {
+					defer r.revalidating.Delete(key)
+					r.fillCache(&discardWriter{header: pipeline}, background, route, policy, base)
+				}()
+			}
+			return
+		}
+	}
+
+	w.Header().Set("X-Cache", "MISS")
+	if h.Method != "GET" {
+		// Responses to HEAD requests have no body, and would be served to GET requests without one
+		r.serveTarget(w, h, route)
+		return
+	}
+	r.fillCache(w, h, route, policy, base)
+}
+
+// fillCache serves the route and stores its response under key, extended by the headers named in its Vary
+// header, if it is cacheable
+func (r *Router) fillCache(w http.ResponseWriter, h *http.Request, route *Route, policy *CachePolicy, key string) {
+	tags := append([]string{}, policy.Tags...)
+	h = h.WithContext(context.WithValue(h.Context(), cacheTagsContextKey{}, &tags))
+
+	maxSize := policy.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxCachedSize
+	}
+	cw := &captureWriter{ResponseWriter: w, maxSize: maxSize, before: w.Header().Clone()}
+	r.serveTarget(cw, h, route)
+	if cw.status == 0 && cw.body.Len() == 0 {
+		cw.capture(http.StatusOK)
+	}
+
+	if !cw.cacheable() {
+		return
+	}
+	r.stripRequestHeaders(cw.header)
+	now := time.Now()
+	if vary := responseVary(cw.header); len(vary) != 0 {
+		// The variants are stored under keys including the headers, and the entry of the request refers to them
+		r.ResponseCache.Set(key, &CachedResponse{Vary: vary, Tags: tags, Created: now,
+			Expires: now.Add(policy.TTL), StaleUntil: now.Add(policy.TTL + policy.StaleWhileRevalidate)})
+		key = varyKey(key, h, vary)
+	}
+	r.ResponseCache.Set(key, &CachedResponse{
+		Status:     cw.status,
+		Header:     cw.header,
+		Body:       cw.body.Bytes(),
+		Tags:       tags,
+		Created:    now,
+		Expires:    now.Add(policy.TTL),
+		StaleUntil: now.Add(policy.TTL + policy.StaleWhileRevalidate),
+	})
+}
+
+// stripRequestHeaders removes the headers belonging to a single request from a captured header, e.g. its
+// request ID and rate limit state. They are set again for every request served from the cache.
+func (r *Router) stripRequestHeaders(header http.Header) {
+	for _, key := range []string{"X-Cache", "Age", "Date", "Set-Cookie", "Retry-After", "RateLimit-Limit",
+		"RateLimit-Remaining", "RateLimit-Reset", "Traceparent", "Tracestate", r.Configuration.RequestID.Header} {
+		if key != "" {
+			header.Del(key)
+		}
+	}
+}
+
+// writeCachedResponse writes a cached response, answering conditional requests with 304. Headers already set
+// for the current request are kept, Vary headers are merged.
+func writeCachedResponse(w http.ResponseWriter, h *http.Request, cached *CachedResponse, state string) {
+	header := w.Header()
+	for key, values := range cached.Header {
+		if _, exists := header[key]; !exists {
+			header[key] = append([]string{}, values...)
+		} else if key == "Vary" {
+			for _, name := range values {
+				if !containsString(header.Values("Vary"), name) {
+					header.Add("Vary", name)
+				}
+			}
+		}
+	}
+	header.Set("X-Cache", state)
+	header.Set("Age", strconv.Itoa(int(time.Since(cached.Created)/time.Second)))
+
+	if cached.Status == http.StatusOK && evaluatePreconditions(h, header) == http.StatusNotModified {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(cached.Status)
+	if h.Method != "HEAD" {
+		w.Write(cached.Body)
+	}
+}
+
+// captureWriter records the response passing through it, to store it in the cache
+type captureWriter struct {
+	http.ResponseWriter
+	// before is the header set before the route has been served, which is not part of the cached response
+	before   http.Header
+	maxSize  int
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+	flushed  bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.capture(status)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// capture records the status and the header set while serving the route
+func (c *captureWriter) capture(status int) {
+	c.status = status
+	c.header = make(http.Header)
+	for key, values := range c.Header() {
+		if !equalValues(c.before[key], values) {
+			c.header[key] = append([]string{}, values...)
+		}
+	}
+}
+
+// Write implements the http.ResponseWriter interface
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.overflow {
+		if c.body.Len()+len(b) > c.maxSize {
+			c.overflow = true
+			c.body.Reset()
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Written returns true, if the response has been started
+func (c *captureWriter) Written() bool {
+	return c.status != 0
+}
+
+// Flush implements the http.Flusher interface. Flushed responses are streams and never cached.
+func (c *captureWriter) Flush() {
+	c.flushed = true
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. It is used by http.ResponseController.
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// cacheable returns true, if the captured response may be cached
+func (c *captureWriter) cacheable() bool {
+	if c.status != http.StatusOK || c.overflow || c.flushed || c.header.Get("Set-Cookie") != "" ||
+		strings.Contains(c.header.Get("Vary"), "*") {
+		return false
+	}
+	cc := strings.ToLower(c.header.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+// discardWriter is the ResponseWriter of background revalidations
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+// equalValues returns true, if both header values are the same
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package wrouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tNewsController struct {
+	calls int32
+}
+
+func (t *tNewsController) ShowAction(w http.ResponseWriter, h *http.Request, c *Caching) {
+	n := atomic.AddInt32(&t.calls, 1)
+	c.Tag("article:" + h.URL.Query().Get("id"))
+	c.SetETag(fmt.Sprint("v", n))
+	fmt.Fprintf(w, "article %s call %d", h.URL.Query().Get("id"), n)
+}
+
+func (t *tNewsController) Post_UpdateAction(h *http.Request, c *Caching) {
+	c.Invalidate("article:" + h.URL.Query().Get("id"))
+}
+
+func (t *tNewsController) PrivateAction(w http.ResponseWriter) {
+	atomic.AddInt32(&t.calls, 1)
+	w.Header().Set("Cache-Control", "private")
+	w.Write([]byte("private"))
+}
+
+func TestResponseCache(t *testing.T) {
+	controller := &tNewsController{}
+	store := NewMemoryResponseCache(DefaultResponseCacheSize)
+	rt := NewRouter()
+	rt.ResponseCache = store
+	rt.Group("/cached").Cache(&CachePolicy{TTL: time.Minute, StaleWhileRevalidate: time.Minute,
+		Query: []string{"id"}}).AddController(controller)
+
+	do := func(method, uri string, header ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, uri, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			request.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		return rec
+	}
+	expect := func(rec *httptest.ResponseRecorder, status int, body, state string) {
+		t.Helper()
+		if rec.Code != status || rec.Body.String() != body || rec.Header().Get("X-Cache") != state {
+			t.Errorf("Expected %d %q %s, got %d %q %s", status, body, state, rec.Code, rec.Body.String(),
+				rec.Header().Get("X-Cache"))
+		}
+	}
+
+	expect(do("GET", "/cached/tnews/show?id=1"), 200, "article 1 call 1", "MISS")
+	expect(do("GET", "/cached/tnews/show?id=1&utm=x"), 200, "article 1 call 1", "HIT")
+	expect(do("GET", "/cached/tnews/show?id=2"), 200, "article 2 call 2", "MISS")
+	expect(do("GET", "/cached/tnews/show?id=1", "If-None-Match", `"v1"`), 304, "", "HIT")
+
+	// Tags invalidate the responses of one article only
+	do("POST", "/cached/tnews/update?id=1")
+	expect(do("GET", "/cached/tnews/show?id=1"), 200, "article 1 call 3", "MISS")
+	expect(do("GET", "/cached/tnews/show?id=2"), 200, "article 2 call 2", "HIT")
+
+	// Stale responses are served while being refreshed in the background
+	show := rt.findRequestRoute(httptest.NewRequest("GET", "/cached/tnews/show", nil))
+	for _, id := range []string{"1", "2"} {
+		key := rt.cacheKey(httptest.NewRequest("GET", "/cached/tnews/show?id="+id, nil), show,
+			show.Metadata[MetaCache].(*CachePolicy))
+		if cached, ok := store.Get(key); ok {
+			cached.Expires = time.Now().Add(-time.Second)
+		} else {
+			t.Fatalf("Missing cached response for %s", key)
+		}
+	}
+	expect(do("GET", "/cached/tnews/show?id=2"), 200, "article 2 call 2", "STALE")
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&controller.calls) < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 100 && do("GET", "/cached/tnews/show?id=2").Header().Get("X-Cache") != "HIT"; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	expect(do("GET", "/cached/tnews/show?id=2"), 200, "article 2 call 4", "HIT")
+
+	// Private responses are not cached
+	do("GET", "/cached/tnews/private")
+	expect(do("GET", "/cached/tnews/private"), 200, "private", "MISS")
+	if calls := atomic.LoadInt32(&controller.calls); calls != 6 {
+		t.Errorf("Expected 6 action calls, got %d", calls)
+	}
+}
+
+func TestResponseCacheHead(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc("/file", []string{"GET", "HEAD"}, func(w http.ResponseWriter, h *http.Request) {
+		http.ServeContent(w, h, "file.txt", time.Time{}, strings.NewReader("hello world"))
+	}).Cache(&CachePolicy{TTL: time.Minute})
+
+	for _, method := range []string{"HEAD", "GET", "HEAD"} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(method, "/file", nil))
+		if rec.Code != 200 || rec.Header().Get("Content-Length") != "11" {
+			t.Errorf("Unexpected %s response: %d %v", method, rec.Code, rec.Header())
+		}
+		if method == "GET" && (rec.Body.String() != "hello world" || rec.Header().Get("X-Cache") != "MISS") {
+			t.Errorf("Expected GET to be served by the handler, got %s %q", rec.Header().Get("X-Cache"),
+				rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/file", nil))
+	if rec.Body.String() != "hello world" || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected the cached body, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+}
+
+func TestResponseCacheRequestHeaders(t *testing.T) {
+	rt := NewRouter()
+	rt.Configuration.RequestID.Enabled = true
+	rt.HandleFunc("/page", nil, func(w http.ResponseWriter, h *http.Request) {
+		w.Header().Set("X-Page", "1")
+		w.Write([]byte("page"))
+	}).Cache(&CachePolicy{TTL: time.Minute}).RateLimit(&RateLimit{Limit: 10, Window: time.Minute})
+
+	for i, state := range []string{"MISS", "HIT", "HIT"} {
+		request := httptest.NewRequest("GET", "/page", nil)
+		request.Header.Set("X-Request-ID", fmt.Sprint("id-", i))
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		header := rec.Header()
+		if header.Get("X-Cache") != state || header.Get("X-Page") != "1" || rec.Body.String() != "page" ||
+			header.Get("X-Request-ID") != fmt.Sprint("id-", i) ||
+			header.Get("RateLimit-Remaining") != fmt.Sprint(9-i) {
+			t.Errorf("Request %d: unexpected response %v %q", i, header, rec.Body.String())
+		}
+	}
+}
+
+func TestMemoryResponseCacheEviction(t *testing.T) {
+	store := NewMemoryResponseCache(250)
+	for i := 0; i < 3; i++ {
+		store.Set(fmt.Sprint(i), &CachedResponse{Status: 200, Body: make([]byte, 100), Tags: []string{"all"},
+			StaleUntil: time.Now().Add(time.Minute)})
+	}
+	if _, ok := store.Get("0"); ok || store.Len() != 2 {
+		t.Errorf("Expected the least recently used response to be evicted, %d remaining", store.Len())
+	}
+	store.Invalidate("all")
+	if store.Len() != 0 {
+		t.Errorf("Expected all responses to be invalidated, %d remaining", store.Len())
+	}
+}
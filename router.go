@@ -1,12 +1,16 @@
 package wrouter
 
 import (
+	"context"
 	"github.com/owtorg/clitable"
 	"github.com/owtorg/events"
 	"io"
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var AllowedMethods = []string{"get", "post", "put", "patch", "head", "trace", "connect", "options", "delete"}
@@ -19,18 +23,60 @@ func (r *Route) AddMethod(method string) {
 
 // Router represents one implementation of the http.Handler interface
 type Router struct {
-	routes    []*Route
-	injectors []Injector
+	routes     []*Route
+	injectors  []Injector
+	middleware []Middleware
+	renderers  []namedRenderer
+
+	// hostRoutes is true, if at least one route is bound to a host pattern
+	hostRoutes bool
 
 	// Eventcollections
 	// See: github.com/owtorg/events
 	preRequest  *events.EventCollection
 	postRequest *events.EventCollection
 
+	accessLog *accessLogger
+	metrics   *metrics
+
 	// Configuration contains the router configuration
 	Configuration   *Configuration
 	RouteResolver   RouteResolver
 	RequestResolver RequestResolver
+
+	// Tracer when set, is used to create spans for the routing, every injector call, the controller action and
+	// the post request events of every request. Incoming W3C trace context headers are continued.
+	// See: NewTracer
+	Tracer Tracer
+
+	// ErrorHandler when set, is called for all errors the router answers on its own, e.g. a 404 if no route
+	// matches the request.
+	ErrorHandler ErrorHandler
+
+	// IdentityProvider when set, resolves the identity of requests to routes with policies, and of actions
+	// declaring an argument of type wrouter.Identity. See: Policy
+	IdentityProvider IdentityProvider
+
+	// SessionStore when set, enables sessions. Actions receive the session of the request by declaring an
+	// argument of type *wrouter.Session. See: Session, NewCookieStore, NewMemoryStore
+	SessionStore SessionStore
+	// SessionErrorHandler when set, is called with the errors of the SessionStore saving the session of a
+	// request. If nil, the errors are written to the standard logger. Requests whose response has not been
+	// written yet, are answered with 500 through the error handling.
+	SessionErrorHandler func(h *http.Request, err error)
+
+	// RateLimitStore counts the requests to routes with a rate limit. See: RateLimit
+	//
+	// Default: NewMemoryRateLimitStore()
+	RateLimitStore RateLimitStore
+
+	// ResponseCache stores the responses of routes with a cache policy. See: CachePolicy
+	//
+	// Default: NewMemoryResponseCache(DefaultResponseCacheSize)
+	ResponseCache ResponseCacheStore
+
+	// revalidating contains the cache keys of stale responses currently being revalidated
+	revalidating sync.Map
 }
 
 // Create a new Router
@@ -38,53 +84,222 @@ func NewRouter() *Router {
 	r := new(Router)
 	r.preRequest = new(events.EventCollection)
 	r.postRequest = new(events.EventCollection)
+	r.accessLog = new(accessLogger)
+	r.metrics = newMetrics()
 	r.Configuration = createDefaultConfiguration()
 	r.RouteResolver = newRouteResolver(r.Configuration)
 	r.RequestResolver = newRqResolver(r)
+	r.RateLimitStore = NewMemoryRateLimitStore()
+	r.ResponseCache = NewMemoryResponseCache(DefaultResponseCacheSize)
 	return r
 }
 
 // ServeHTTP satisfies the http.Handler interface, so that this Router can be used as the
 // second parameter of http.ListenAndServe
-func (r *Router) ServeHTTP(w http.ResponseWriter, h *http.Request) {
+func (r *Router) ServeHTTP(rw http.ResponseWriter, h *http.Request) {
+	start := time.Now()
+
+	// The ResponseWriter is wrapped to keep track of the status code and the written bytes
+	rec := newResponseWriter(rw)
+	var w http.ResponseWriter = rec
+
+	var requestID RequestID
+	if r.Configuration.RequestID.Enabled {
+		h, requestID = r.assignRequestID(w, h)
+	}
+
+	var session *sessionState
+	if r.SessionStore != nil {
+		h, session = r.startSession(rec, h)
+	}
+
+	// Start the server span and propagate it through the request context
+	var span Span = noopSpan{}
+	if r.Tracer != nil {
+		var ctx context.Context
+		ctx, span = r.Tracer.Start(ExtractTraceContext(h.Context(), h.Header), "HTTP "+h.Method)
+		span.SetAttribute("span.kind", "server")
+		span.SetAttribute("http.method", h.Method)
+		span.SetAttribute("http.target", h.URL.RequestURI())
+		if requestID != "" {
+			span.SetAttribute("http.request_id", string(requestID))
+		}
+		h = h.WithContext(ctx)
+		defer func() {
+			span.SetAttribute("http.status_code", rec.Status())
+			span.End()
+		}()
+	}
+
 	// Execute PreRequestEvents if any
 	if r.preRequest.Len() != 0 {
 		ctx := createPreRequestEventContext(h, w)
 		events.DispatchEvents(r.preRequest, ctx)
+		h, w = ctx.Request, ctx.ResponseWriter
+	}
+
+	canonical := r.canonicalize(w, h)
+	if canonical == nil {
+		r.finishRequest(rec, h, nil, start)
+		return
+	}
+	h = canonical
+
+	if r.isMetricsRequest(h) {
+		r.metrics.serve(w, r.RequestResolver)
+		return
+	}
+
+	if isPreflight(h) && r.servePreflight(w, h) {
+		r.finishRequest(rec, h, nil, start)
+		return
 	}
 
+	_, routingSpan := r.startSpan(h.Context(), "routing")
 	route := r.findRequestRoute(h)
+	if route == nil && r.Configuration.Rendering.PathExtensions && len(r.renderers) != 0 {
+		h, route = r.resolveFormatExtension(h)
+	}
+	if route != nil {
+		routingSpan.SetAttribute("http.route", "/"+route.Path)
+		span.SetAttribute("http.route", "/"+route.Path)
+	}
+	routingSpan.End()
+	r.applyCORS(w, h, route)
+
+	route, ok := r.applyTrailingSlash(w, h, route)
+	if !ok {
+		r.finishRequest(rec, h, route, start)
+		return
+	}
+
+	r.dispatch(w, h, route)
+
+	// Sessions of requests whose response has not been written yet are saved now, so that the cookie is
+	// still part of the header
+	if session != nil {
+		if err := session.save(rec); err != nil && !rec.Written() {
+			r.serveError(rec, h, http.StatusInternalServerError)
+		}
+	}
+	r.finishRequest(rec, h, route, start)
+}
+
+// finishRequest records the metrics and writes the access log of a served request
+func (r *Router) finishRequest(rec *responseWriter, h *http.Request, route *Route, start time.Time) {
+	if r.Configuration.Metrics.Enabled {
+		r.metrics.observe(r.Configuration, route, h.Method, rec.Status(), time.Since(start))
+	}
+
+	if r.Configuration.AccessLog.Enabled {
+		r.accessLog.log(r.Configuration, rec, h, route, start)
+	}
+}
+
+// serveRoute serves a resolved route. It is the last element of the middleware chain.
+func (r *Router) serveRoute(w http.ResponseWriter, h *http.Request, route *Route) {
 	if route == nil {
-		// @TODO: Implement check if an ErrorController exists
-		// Define Specifications for ErrorController (ex: StatusNotFoundAction??)
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("Not Found"))
+		r.serveError(w, h, http.StatusNotFound)
+		return
+	}
+
+	if w2, cw := r.compressResponse(w, h, route); cw != nil {
+		w = w2
+		defer cw.close()
+	}
+
+	h, body := r.limitBody(w, h, route)
+	if h == nil {
+		return
+	}
+	r.setReadDeadline(w, route)
+
+	timedOut := false
+	if timeout := r.routeTimeout(route); timeout > 0 {
+		timedOut = r.serveWithTimeout(w, h, route, timeout, r.serveMatchedRoute)
+	} else {
+		r.serveMatchedRoute(w, h, route)
+	}
+
+	// Actions failing to read a too large body usually do not write a response. Timed out requests have been
+	// answered already.
+	if !timedOut && body != nil && body.isExceeded() && !responseWritten(w) {
+		r.serveError(w, h, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// serveMatchedRoute serves a resolved route within its limits
+func (r *Router) serveMatchedRoute(w http.ResponseWriter, h *http.Request, route *Route) {
+	if !r.limitRate(w, h, route) {
+		return
+	}
+
+	if h = r.authorize(w, h, route); h == nil {
+		return
+	}
+
+	if r.Configuration.CSRF.Enabled {
+		if h = r.protectCSRF(w, h, route); h == nil {
+			return
+		}
+	}
+
+	if policy, ok := route.Metadata[MetaCache].(*CachePolicy); ok && (h.Method == "GET" || h.Method == "HEAD") {
+		r.serveCached(w, h, route, policy)
+		return
+	}
+	r.serveTarget(w, h, route)
+}
+
+// serveTarget calls the handler or the controller action of the route
+func (r *Router) serveTarget(w http.ResponseWriter, h *http.Request, route *Route) {
+	if route.Handler != nil {
+		_, span := r.startSpan(h.Context(), "handler")
+		span.SetAttribute("code.function", route.actionName())
+		r.serveHandler(route, w, h)
+		span.End()
+
+		// Handlers participate in the PostRequest events, but have no return values
+		if r.postRequest.Len() != 0 {
+			ctx := createPostRequestEventContext(h, w, nil)
+			events.DispatchEvents(r.postRequest, ctx)
+		}
 		return
 	}
 
 	r.callRoute(route, w, h)
 }
 
+// isMetricsRequest returns true, if metrics are enabled and the request targets the metrics path
+func (r *Router) isMetricsRequest(h *http.Request) bool {
+	return r.Configuration.Metrics.Enabled && h.Method == "GET" &&
+		strings.Trim(h.URL.Path, "/") == strings.Trim(r.Configuration.Metrics.Path, "/")
+}
+
 // PrintRoutes will print out all routes to io.Writer
 func (r *Router) PrintRoutes(writer io.Writer) {
 	t := clitable.New()
-	t.AddRow("ID", "METHODS", "PATH")
+	t.AddRow("ID", "METHODS", "PATH", "METADATA")
 	for i, route := range r.routes {
 		ms := ""
 		for _, me := range route.Methods {
 			ms += me + " "
 		}
-		t.AddRow(strconv.Itoa(i), ms, route.Path)
+		if route.Host != "" {
+			ms += "(" + route.Host + ") "
+		}
+		path := route.Path
+		if route.Prefix {
+			path = strings.TrimPrefix(path+"/*", "/")
+		}
+		t.AddRow(strconv.Itoa(i), ms, path, formatMetadata(route.Metadata))
 	}
 	t.Fprint(writer)
 }
 
 // AddController will add a new controller to the router.
 func (r *Router) AddController(controller interface{}) {
-	routes, err := r.RouteResolver.Resolve(controller)
-	if err != nil {
-		panic(err)
-	}
+	routes := r.resolveController(controller)
 	if len(routes) > 0 {
 		for i := 0; i < len(routes); i++ {
 			r.AddRoute(routes[i])
@@ -94,6 +309,10 @@ func (r *Router) AddController(controller interface{}) {
 
 // AddRoute will add a new Route to a controller.
 func (r *Router) AddRoute(route *Route) {
+	if route.Host != "" {
+		route.host = compileHostPattern(route.Host)
+		r.hostRoutes = true
+	}
 	r.routes = append(r.routes, route)
 }
 
@@ -132,15 +351,108 @@ func (r *Router) findRequestRoute(h *http.Request) *Route {
 	return r.RequestResolver.Resolve(h)
 }
 
+// startSpan starts a span with the configured Tracer. If no Tracer is configured, a no-op span is returned.
+func (r *Router) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if r.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return r.Tracer.Start(ctx, name)
+}
+
 func (r *Router) callRoute(route *Route, w http.ResponseWriter, h *http.Request) {
-	values := make([]reflect.Value, 0)
+	// The action span covers the filters, the injection of the arguments and the call itself. The request
+	// handed to the action carries the span, so that actions can create child spans.
+	actionCtx, actionSpan := r.startSpan(h.Context(), "action")
+	actionSpan.SetAttribute("code.function", route.actionName())
+	ah := h
+	if r.Tracer != nil {
+		ah = h.WithContext(actionCtx)
+	}
 
-	ctx := createInjectorContext(h, route, r, w)
+	if cc := route.Metadata.String(MetaCacheControl); cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+	if cw := r.conditionalResponse(w, h, route); cw != nil {
+		w = cw
+		defer cw.finish()
+	}
+
+	// The format is negotiated before the action is called, so that unacceptable requests have no effect
+	var renderer *namedRenderer
+	if r.rendersValues(route) {
+		if renderer = r.negotiateRenderer(w, h, route); renderer == nil {
+			actionSpan.End()
+			r.serveError(w, h, http.StatusNotAcceptable)
+			return
+		}
+	}
+
+	chain := r.controllerChain(route)
+	controller := chain[len(chain)-1]
+	ctx := createInjectorContext(ah, route, r, w)
+	ctx.Controller = controller.Interface()
+
+	invoke := func() []reflect.Value {
+		values := r.actionArguments(route, controller, ctx)
+
+		if hook, ok := ctx.Controller.(BeforeActionHook); ok {
+			hook.BeforeAction(ctx)
+		}
+		ret := route.RMethod.Func.Call(values)
+		if hook, ok := ctx.Controller.(AfterActionHook); ok {
+			hook.AfterAction(ctx)
+		}
+		return ret
+	}
+
+	var ret []reflect.Value
+	filters := createFilterChain(route, chain)
+	if filters.empty() {
+		ret = invoke()
+	} else {
+		fctx := &FilterContext{InjectorContext: ctx}
+		if !filters.run(fctx, invoke) {
+			actionSpan.End()
+			r.abortRequest(fctx, w, h)
+			return
+		}
+		ret = fctx.Values
+	}
+	actionSpan.End()
+	if ctx.eventStream != nil {
+		ctx.eventStream.close()
+	}
+	applyValidators(w, ret)
+
+	// Channels of events are streamed instead of being rendered
+	if r.streamValues(w, ah, ret) {
+		renderer = nil
+	}
+
+	// Render the returned values with the negotiated renderer, and execute PostRequest events, which
+	// typically render the returned values if no renderers are registered
+	if renderer != nil || r.postRequest.Len() != 0 {
+		_, renderSpan := r.startSpan(h.Context(), "render")
+		if renderer != nil {
+			renderSpan.SetAttribute("render.format", renderer.format)
+			r.render(w, h, route, renderer, ret)
+		}
+		if r.postRequest.Len() != 0 {
+			ctx := createPostRequestEventContext(h, w, ret)
+			events.DispatchEvents(r.postRequest, ctx)
+		}
+		renderSpan.End()
+	}
+}
+
+// actionArguments creates the arguments of the action call
+func (r *Router) actionArguments(route *Route, controller reflect.Value, ctx *InjectorContext) []reflect.Value {
+	values := make([]reflect.Value, 0, route.RMethod.Type.NumIn())
 
 	// Currently, every controller action needs to be part of a struct, therefore
 	// the first argument of the method, is the struct itself. This happens implicit
 	// when a method is defined as func (s *struct) doit()
-	values = append(values, reflect.ValueOf(route.Controller))
+	values = append(values, controller)
 
 	// argument resolving switch is only called, if a method has more than one argument
 	if route.RMethod.Type.NumIn() > 1 {
@@ -149,29 +461,51 @@ func (r *Router) callRoute(route *Route, w http.ResponseWriter, h *http.Request)
 			arg := route.RMethod.Type.In(i)
 			switch arg.String() {
 			case "http.ResponseWriter":
-				values = append(values, reflect.ValueOf(w))
+				values = append(values, reflect.ValueOf(ctx.ResponseWriter))
 			case "*http.Request":
-				values = append(values, reflect.ValueOf(h))
+				values = append(values, reflect.ValueOf(ctx.Request))
+			case "wrouter.RequestID":
+				values = append(values, reflect.ValueOf(ctx.RequestID))
+			case "wrouter.HostParams":
+				values = append(values, reflect.ValueOf(route.hostParams(ctx.Request.Host)))
+			case "*wrouter.Session":
+				values = append(values, reflect.ValueOf(SessionFromContext(ctx.Request.Context())))
+			case "*wrouter.EventStream":
+				if ctx.eventStream == nil {
+					ctx.eventStream = r.newEventStream(ctx.ResponseWriter, ctx.Request)
+				}
+				values = append(values, reflect.ValueOf(ctx.eventStream))
+			case "*wrouter.Caching":
+				values = append(values, reflect.ValueOf(newCaching(ctx)))
+			case "wrouter.CSRFToken":
+				values = append(values, reflect.ValueOf(CSRFToken(CSRFTokenFromContext(ctx.Request.Context()))))
+			case "wrouter.Identity":
+				if id := r.identity(ctx); id != nil {
+					values = append(values, reflect.ValueOf(&id).Elem())
+				} else {
+					values = append(values, reflect.Zero(arg))
+				}
 			default:
-				values = append(values, reflect.ValueOf(r.inject(arg.String(), ctx)))
+				v := reflect.ValueOf(r.inject(arg.String(), ctx))
+				if !v.IsValid() || !v.Type().AssignableTo(arg) {
+					// No injector supports the type, or it returned nil or a value of another type
+					v = reflect.Zero(arg)
+				}
+				values = append(values, v)
 			}
 		}
 	}
-
-	ret := route.RMethod.Func.Call(values)
-
-	// Execute PostRequest events
-	if r.postRequest.Len() != 0 {
-		ctx := createPostRequestEventContext(h, w, ret)
-		events.DispatchEvents(r.postRequest, ctx)
-	}
+	return values
 }
 
 func (r *Router) inject(t string, ctx *InjectorContext) interface{} {
 	if len(r.injectors) != 0 {
 		for _, injector := range r.injectors {
 			if injector.Supports(t) {
-				return injector.Get(ctx)
+				_, span := r.startSpan(ctx.Request.Context(), "inject "+t)
+				v := injector.Get(ctx)
+				span.End()
+				return v
 			}
 		}
 	}
@@ -1,6 +1,9 @@
 package wrouter
 
-import "reflect"
+import (
+	"net/http"
+	"reflect"
+)
 
 // Route represents one callable route
 type Route struct {
@@ -12,4 +15,91 @@ type Route struct {
 	RMethod reflect.Method
 	// Path contains the path for the current route
 	Path string
+	// Metadata contains arbitrary information attached to the current route
+	// See: MetadataProvider
+	Metadata Metadata
+	// Handler contains the http.Handler called on the current route, if the route has been created by
+	// Router.Handle or Router.Mount instead of a Controller
+	Handler http.Handler
+	// Prefix when set to true, makes the route match every path below Path. The Handler receives the request
+	// with Path stripped from the URL.
+	Prefix bool
+	// Host contains the host pattern the current route is bound to. An empty Host matches every host.
+	// The pattern is compiled by Router.AddRoute; later changes have no effect.
+	// See: Group.Host
+	Host string
+
+	host *hostPattern
+
+	// actionPath is the path of a controller action as resolved from the controller, including the paths of
+	// parent controllers, but without group prefixes and alias shortening. See: viewName
+	actionPath string
+	// aliasOf is set on alias routes to the route they are an alias of
+	aliasOf *Route
+
+	// lifetime, factory, chain and fields are set for routes of controllers, to create the controller
+	// instances serving a request. See: Router.controllerChain
+	lifetime ControllerLifetime
+	factory  func() Controller
+	chain    []Controller
+	fields   []int
+}
+
+// actionName returns the name of the controller action called by the route, e.g. "UserController.ShowAction"
+func (r *Route) actionName() string {
+	if r.Handler != nil {
+		return reflect.TypeOf(r.Handler).String()
+	}
+	if r.Controller == nil {
+		return ""
+	}
+	rc := reflect.TypeOf(r.Controller)
+	if rc.Kind() == reflect.Ptr {
+		rc = rc.Elem()
+	}
+	return rc.Name() + "." + r.RMethod.Name
+}
+
+// hasMethod returns true, if the route is callable by the given HTTP method
+func (r *Route) hasMethod(method string) bool {
+	// reduce complexity for routes with only one method
+	if len(r.Methods) == 1 {
+		return r.Methods[0] == method
+	}
+	for _, me := range r.Methods {
+		if me == method {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPrefix returns true, if the route is a prefix route and the given path is equal to or below its path
+func (r *Route) matchesPrefix(path string, caseSensitive bool) bool {
+	if !r.Prefix {
+		return false
+	}
+	if r.Path == "" || equalPath(r.Path, path, caseSensitive) {
+		return true
+	}
+	return len(path) > len(r.Path) && path[len(r.Path)] == '/' &&
+		equalPath(r.Path, path[:len(r.Path)], caseSensitive)
+}
+
+// matchesHost returns true, if the route is not bound to a host or the given host matches its host pattern
+func (r *Route) matchesHost(host string) bool {
+	if r.host == nil {
+		return true
+	}
+	_, ok := r.host.match(host)
+	return ok
+}
+
+// hostParams returns the values matched by the host pattern of the route
+func (r *Route) hostParams(host string) HostParams {
+	if r.host == nil {
+		return nil
+	}
+	params, _ := r.host.match(host)
+	return params
 }
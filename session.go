@@ -0,0 +1,279 @@
+package wrouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Session contains the values of a client session. It can be injected into controller actions by declaring an
+// argument of type *wrouter.Session, or taken from the request context by SessionFromContext. Sessions are
+// loaded on first use, and saved automatically before the response header is written, or after the request
+// has been served if the action did not write a response. Changes made after the header has been written are
+// lost. See Router.SessionStore
+//
+// Values and flashes of custom types must be registered with gob.Register, as stores serialize them with gob.
+type Session struct {
+	id        string
+	values    map[string]interface{}
+	flashes   []interface{}
+	created   time.Time
+	isNew     bool
+	modified  bool
+	rotate    bool
+	destroyed bool
+}
+
+// sessionData is the serialized form of a session
+type sessionData struct {
+	ID      string
+	Values  map[string]interface{}
+	Flashes []interface{}
+	Created time.Time
+}
+
+// NewSession creates a new, empty session with a random ID. Stores use it, if a request has no session yet.
+func NewSession() *Session {
+	return &Session{
+		id:      generateSessionID(),
+		values:  make(map[string]interface{}),
+		created: time.Now(),
+		isNew:   true,
+	}
+}
+
+func newSessionFromData(data *sessionData) *Session {
+	s := &Session{id: data.ID, values: data.Values, flashes: data.Flashes, created: data.Created}
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	return s
+}
+
+func (s *Session) data() *sessionData {
+	return &sessionData{ID: s.id, Values: s.values, Flashes: s.flashes, Created: s.created}
+}
+
+// ID returns the ID of the session. It changes when the session is rotated.
+func (s *Session) ID() string { return s.id }
+
+// Created returns the time the session has been created
+func (s *Session) Created() time.Time { return s.created }
+
+// IsNew returns true, if the session has been created during the current request
+func (s *Session) IsNew() bool { return s.isNew }
+
+// Modified returns true, if the session has been changed during the current request and will be saved
+func (s *Session) Modified() bool { return s.modified }
+
+// Get returns the value stored under key, or nil
+func (s *Session) Get(key string) interface{} {
+	return s.values[key]
+}
+
+// String returns the value stored under key, if it is a string
+func (s *Session) String(key string) string {
+	v, _ := s.values[key].(string)
+	return v
+}
+
+// Set stores a value under key
+func (s *Session) Set(key string, value interface{}) {
+	s.values[key] = value
+	s.modified = true
+}
+
+// Delete removes the value stored under key
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Clear removes all values and flashes
+func (s *Session) Clear() {
+	s.values = make(map[string]interface{})
+	s.flashes = nil
+	s.modified = true
+}
+
+// AddFlash adds a flash message, which is kept until it is read by Flashes, typically after a redirect
+func (s *Session) AddFlash(value interface{}) {
+	s.flashes = append(s.flashes, value)
+	s.modified = true
+}
+
+// Flashes returns and removes all flash messages
+func (s *Session) Flashes() []interface{} {
+	flashes := s.flashes
+	if len(flashes) != 0 {
+		s.flashes = nil
+		s.modified = true
+	}
+	return flashes
+}
+
+// Rotate assigns a new ID to the session while keeping its values. The old ID is invalidated when the session
+// is saved. Sessions should be rotated when the privileges of the client change, e.g. after login, to prevent
+// session fixation.
+func (s *Session) Rotate() {
+	s.rotate = true
+	s.modified = true
+}
+
+// Destroy removes the session from the store and the client when it is saved
+func (s *Session) Destroy() {
+	s.destroyed = true
+	s.modified = true
+}
+
+// generateSessionID creates 32 random bytes in URL safe base64 representation
+func generateSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SessionStore loads and saves sessions. See NewCookieStore, NewMemoryStore and NewBackendStore
+type SessionStore interface {
+	// Load returns the session of the request. If the request has no valid session, a new one is returned.
+	Load(h *http.Request) (*Session, error)
+	// Save persists the session and sets the session cookie. It is only called for modified sessions.
+	Save(w http.ResponseWriter, h *http.Request, s *Session) error
+}
+
+// SessionOptions contains the cookie settings of a session store
+type SessionOptions struct {
+	// CookieName is the name of the session cookie
+	CookieName string
+	// Path is the path of the session cookie
+	Path string
+	// Domain is the domain of the session cookie. If empty, the cookie is bound to the host of the request.
+	Domain string
+	// MaxAge is the lifetime of the session. Zero makes the cookie a browser session cookie, while the
+	// session data expires after 24 hours.
+	MaxAge time.Duration
+	// Secure restricts the cookie to HTTPS
+	Secure bool
+	// HttpOnly hides the cookie from scripts
+	HttpOnly bool
+	// SameSite is the SameSite attribute of the cookie
+	SameSite http.SameSite
+}
+
+// DefaultSessionOptions returns the default options of session stores
+func DefaultSessionOptions() *SessionOptions {
+	return &SessionOptions{
+		CookieName: "session",
+		Path:       "/",
+		MaxAge:     24 * time.Hour,
+		HttpOnly:   true,
+		SameSite:   http.SameSiteLaxMode,
+	}
+}
+
+// ttl returns the time the session data is kept
+func (o *SessionOptions) ttl() time.Duration {
+	if o.MaxAge > 0 {
+		return o.MaxAge
+	}
+	return 24 * time.Hour
+}
+
+// cookie creates the session cookie with the given value. If value is empty, the cookie is deleted.
+func (o *SessionOptions) cookie(value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     o.CookieName,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	} else if o.MaxAge > 0 {
+		c.MaxAge = int(o.MaxAge / time.Second)
+		c.Expires = time.Now().Add(o.MaxAge)
+	}
+	return c
+}
+
+type sessionContextKey struct{}
+
+// sessionState holds the session of a request. The session is loaded on first access.
+type sessionState struct {
+	router  *Router
+	store   SessionStore
+	request *http.Request
+	session *Session
+	saved   bool
+	err     error
+	// abandoned is set when the request timed out while its action may still use the session
+	abandoned bool
+}
+
+// SessionFromContext returns the session of the request the context belongs to, or nil if sessions are
+// disabled. The session is loaded on first access.
+func SessionFromContext(ctx context.Context) *Session {
+	state, _ := ctx.Value(sessionContextKey{}).(*sessionState)
+	if state == nil {
+		return nil
+	}
+	return state.get()
+}
+
+func (s *sessionState) get() *Session {
+	if s.session == nil {
+		session, err := s.store.Load(s.request)
+		if err != nil || session == nil {
+			// Invalid sessions, e.g. tampered cookies or unavailable backends, are replaced by a new session
+			session = NewSession()
+		}
+		s.session = session
+	}
+	return s.session
+}
+
+// save saves the session, if it has been loaded and modified. Once saved or failed, further calls have no
+// effect. Errors of the store are reported to the SessionErrorHandler and returned.
+func (s *sessionState) save(w http.ResponseWriter) error {
+	if s.err != nil || s.abandoned || s.saved || s.session == nil || !s.session.modified {
+		return s.err
+	}
+	if err := s.store.Save(w, s.request, s.session); err != nil {
+		s.err = err
+		if s.router.SessionErrorHandler != nil {
+			s.router.SessionErrorHandler(s.request, err)
+		} else {
+			log.Printf("wrouter: saving the session of %s %s failed: %v", s.request.Method, s.request.URL, err)
+		}
+		return err
+	}
+	s.saved = true
+	return nil
+}
+
+// abandonSession prevents the session of the request from being saved. It is called when the action of a
+// timed out request keeps running, and may still change the session.
+func abandonSession(ctx context.Context) {
+	if state, _ := ctx.Value(sessionContextKey{}).(*sessionState); state != nil {
+		state.abandoned = true
+	}
+}
+
+// startSession stores the session state of the request in its context. The session is saved before the
+// header is written to rec.
+func (r *Router) startSession(rec *responseWriter, h *http.Request) (*http.Request, *sessionState) {
+	state := &sessionState{router: r, store: r.SessionStore}
+	h = h.WithContext(context.WithValue(h.Context(), sessionContextKey{}, state))
+	state.request = h
+	// Errors are reported by save, the status of the response cannot be changed anymore
+	rec.beforeHeader = func() { state.save(rec) }
+	return h, state
+}
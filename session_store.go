@@ -0,0 +1,327 @@
+package wrouter
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by session stores
+var (
+	// InvalidSessionCookieError indicates that a session cookie has been tampered with or cannot be decoded
+	InvalidSessionCookieError = errors.New("Invalid session cookie")
+	// SessionExpiredError indicates that the session stored in a cookie has expired
+	SessionExpiredError = errors.New("Session expired")
+	// SessionTooLargeError indicates that an encoded session exceeds the maximum cookie size
+	SessionTooLargeError = errors.New("Session exceeds the maximum cookie size")
+)
+
+// maxCookieSize is the maximum size of a session cookie value accepted by common browsers
+const maxCookieSize = 4000
+
+func encodeSession(s *Session) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(s.data()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSession(b []byte) (*Session, error) {
+	data := new(sessionData)
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(data); err != nil {
+		return nil, err
+	}
+	return newSessionFromData(data), nil
+}
+
+// CookieStore stores sessions in the session cookie itself. The cookie is signed with HMAC-SHA256 and, if a
+// block key is set, encrypted with AES-GCM, so that clients can neither read nor change the session. The
+// serialized session must not exceed roughly 4KB.
+type CookieStore struct {
+	// Options contains the cookie settings
+	Options *SessionOptions
+
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// NewCookieStore creates a CookieStore. The hashKey signs the cookies and should be at least 32 random bytes.
+// The blockKey encrypts the cookies and must have a length of 16, 24 or 32 bytes for AES-128, AES-192 or
+// AES-256. If blockKey is nil, cookies are signed, but not encrypted.
+func NewCookieStore(hashKey, blockKey []byte) (*CookieStore, error) {
+	if len(hashKey) == 0 {
+		return nil, errors.New("A cookie store requires a hash key")
+	}
+	store := &CookieStore{Options: DefaultSessionOptions(), hashKey: hashKey}
+	if blockKey != nil {
+		block, err := aes.NewCipher(blockKey)
+		if err != nil {
+			return nil, err
+		}
+		store.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Load implements the SessionStore interface
+func (c *CookieStore) Load(h *http.Request) (*Session, error) {
+	cookie, err := h.Cookie(c.Options.CookieName)
+	if err != nil {
+		return NewSession(), nil
+	}
+	b, err := c.decode(cookie.Value)
+	if err != nil {
+		return NewSession(), err
+	}
+	return decodeSession(b)
+}
+
+// Save implements the SessionStore interface
+func (c *CookieStore) Save(w http.ResponseWriter, h *http.Request, s *Session) error {
+	if s.destroyed {
+		http.SetCookie(w, c.Options.cookie(""))
+		return nil
+	}
+	if s.rotate {
+		s.id = generateSessionID()
+		s.rotate = false
+	}
+
+	b, err := encodeSession(s)
+	if err != nil {
+		return err
+	}
+	value, err := c.encode(b)
+	if err != nil {
+		return err
+	}
+	if len(value) > maxCookieSize {
+		return SessionTooLargeError
+	}
+	http.SetCookie(w, c.Options.cookie(value))
+	return nil
+}
+
+// encode encrypts b and signs it together with the cookie name and the current time. The result has the form
+// <timestamp>|<payload>|<signature>.
+func (c *CookieStore) encode(b []byte) (string, error) {
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		b = c.aead.Seal(nonce, nonce, b, []byte(c.Options.CookieName))
+	}
+
+	value := strconv.FormatInt(time.Now().Unix(), 10) + "|" + base64.RawURLEncoding.EncodeToString(b)
+	return value + "|" + base64.RawURLEncoding.EncodeToString(c.sign(value)), nil
+}
+
+// decode verifies the signature and the age of a cookie value and returns the decrypted payload
+func (c *CookieStore) decode(value string) ([]byte, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 {
+		return nil, InvalidSessionCookieError
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, c.sign(parts[0]+"|"+parts[1])) {
+		return nil, InvalidSessionCookieError
+	}
+
+	timestamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, InvalidSessionCookieError
+	}
+	if time.Since(time.Unix(timestamp, 0)) > c.Options.ttl() {
+		return nil, SessionExpiredError
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, InvalidSessionCookieError
+	}
+	if c.aead != nil {
+		if len(b) < c.aead.NonceSize() {
+			return nil, InvalidSessionCookieError
+		}
+		b, err = c.aead.Open(nil, b[:c.aead.NonceSize()], b[c.aead.NonceSize():], []byte(c.Options.CookieName))
+		if err != nil {
+			return nil, InvalidSessionCookieError
+		}
+	}
+	return b, nil
+}
+
+func (c *CookieStore) sign(value string) []byte {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(c.Options.CookieName + "|" + value))
+	return mac.Sum(nil)
+}
+
+// SessionBackend is a key-value storage for server side sessions, e.g. backed by Redis or a database.
+// See NewBackendStore
+type SessionBackend interface {
+	// Load returns the data stored under id. If it does not exist or has expired, nil and no error are
+	// returned.
+	Load(id string) ([]byte, error)
+	// Store stores data under id, expiring after ttl
+	Store(id string, data []byte, ttl time.Duration) error
+	// Delete removes the data stored under id
+	Delete(id string) error
+}
+
+// BackendStore stores sessions server side in a SessionBackend. The session cookie only contains the session
+// ID.
+type BackendStore struct {
+	// Options contains the cookie settings. Options.MaxAge also defines the expiry of the stored sessions.
+	Options *SessionOptions
+	// Backend stores the serialized sessions
+	Backend SessionBackend
+}
+
+// NewBackendStore creates a BackendStore using the given backend
+func NewBackendStore(backend SessionBackend) *BackendStore {
+	return &BackendStore{Options: DefaultSessionOptions(), Backend: backend}
+}
+
+// NewMemoryStore creates a BackendStore keeping the sessions in memory. Sessions are lost on restart and are
+// not shared between processes.
+func NewMemoryStore() *BackendStore {
+	return NewBackendStore(NewMemoryBackend())
+}
+
+// Load implements the SessionStore interface
+func (b *BackendStore) Load(h *http.Request) (*Session, error) {
+	cookie, err := h.Cookie(b.Options.CookieName)
+	if err != nil || cookie.Value == "" {
+		return NewSession(), nil
+	}
+	data, err := b.Backend.Load(cookie.Value)
+	if err != nil {
+		return NewSession(), err
+	}
+	if data == nil {
+		return NewSession(), nil
+	}
+	s, err := decodeSession(data)
+	if err != nil {
+		return NewSession(), err
+	}
+	// The ID is taken from the cookie, so that a stored session can only be loaded by its own ID
+	s.id = cookie.Value
+	return s, nil
+}
+
+// Save implements the SessionStore interface
+func (b *BackendStore) Save(w http.ResponseWriter, h *http.Request, s *Session) error {
+	if s.destroyed || s.rotate {
+		if !s.isNew {
+			if err := b.Backend.Delete(s.id); err != nil {
+				return err
+			}
+		}
+		if s.destroyed {
+			http.SetCookie(w, b.Options.cookie(""))
+			return nil
+		}
+		s.id = generateSessionID()
+		s.rotate = false
+	}
+
+	data, err := encodeSession(s)
+	if err != nil {
+		return err
+	}
+	if err := b.Backend.Store(s.id, data, b.Options.ttl()); err != nil {
+		return err
+	}
+	http.SetCookie(w, b.Options.cookie(s.id))
+	return nil
+}
+
+// MemoryBackend is a SessionBackend keeping the sessions in memory. Expired sessions are removed periodically
+// while storing sessions.
+type MemoryBackend struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// sweepInterval is the minimum interval between two removals of expired sessions
+const sweepInterval = time.Minute
+
+// NewMemoryBackend creates an empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{entries: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+// Load implements the SessionBackend interface
+func (m *MemoryBackend) Load(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, id)
+		return nil, nil
+	}
+	return entry.data, nil
+}
+
+// Store implements the SessionBackend interface
+func (m *MemoryBackend) Store(id string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+	m.entries[id] = memoryEntry{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete implements the SessionBackend interface
+func (m *MemoryBackend) Delete(id string) error {
+	m.mu.Lock()
+	delete(m.entries, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of stored sessions, including expired sessions which have not been removed yet
+func (m *MemoryBackend) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func (m *MemoryBackend) sweep(now time.Time) {
+	for id, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, id)
+		}
+	}
+	m.lastSweep = now
+}
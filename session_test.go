@@ -0,0 +1,172 @@
+package wrouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type tCartController struct{}
+
+func (t *tCartController) Post_AddAction(w http.ResponseWriter, h *http.Request, s *Session) {
+	count, _ := s.Get("count").(int)
+	s.Set("count", count+1)
+	s.AddFlash("added " + h.URL.Query().Get("item"))
+	w.Write([]byte("ok"))
+}
+
+func (t *tCartController) ShowAction(w http.ResponseWriter, s *Session) {
+	fmt.Fprintf(w, "%v %v", s.Get("count"), s.Flashes())
+}
+
+func (t *tCartController) Post_LoginAction(s *Session) {
+	s.Rotate()
+}
+
+func (t *tCartController) Post_LogoutAction(s *Session) {
+	s.Destroy()
+}
+
+// tSessionClient sends requests to a router, keeping the session cookie like a browser
+type tSessionClient struct {
+	t      *testing.T
+	router *Router
+	cookie *http.Cookie
+}
+
+func (c *tSessionClient) do(method, uri string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, uri, nil)
+	if c.cookie != nil {
+		request.AddCookie(c.cookie)
+	}
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, request)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = cookie
+		}
+	}
+	return rec
+}
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	rt := NewRouter()
+	rt.SessionStore = store
+	rt.AddController(&tCartController{})
+	client := &tSessionClient{t: t, router: rt}
+
+	if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "<nil> []" || client.cookie != nil {
+		t.Errorf("Unmodified new session must not be saved: %q %v", rec.Body.String(), client.cookie)
+	}
+
+	client.do("POST", "/tcart/add?item=apple")
+	if client.cookie == nil {
+		t.Fatalf("Missing session cookie")
+	}
+	if !client.cookie.HttpOnly || client.cookie.Path != "/" {
+		t.Errorf("Unexpected cookie attributes %v", client.cookie)
+	}
+	client.do("POST", "/tcart/add?item=pear")
+	if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "2 [added apple added pear]" {
+		t.Errorf("Unexpected session content %q", rec.Body.String())
+	}
+	if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "2 []" {
+		t.Errorf("Flashes must be consumed, got %q", rec.Body.String())
+	}
+
+	before := *client.cookie
+	client.do("POST", "/tcart/login")
+	if client.cookie.Value == before.Value {
+		t.Errorf("Session cookie has not been rotated")
+	}
+	if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "2 []" {
+		t.Errorf("Rotated session lost its values: %q", rec.Body.String())
+	}
+
+	client.do("POST", "/tcart/logout")
+	if client.cookie != nil {
+		t.Errorf("Destroyed session must expire the cookie")
+	}
+	client.cookie = &before
+	if _, ok := store.(*BackendStore); ok {
+		if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "<nil> []" {
+			t.Errorf("Old session ID must be invalid after rotation: %q", rec.Body.String())
+		}
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	store, err := NewCookieStore([]byte("0123456789abcdef0123456789abcdef"), []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSessionStore(t, store)
+
+	// Tampered cookies are replaced by a new session
+	rt := NewRouter()
+	rt.SessionStore = store
+	rt.AddController(&tCartController{})
+	client := &tSessionClient{t: t, router: rt}
+	client.do("POST", "/tcart/add")
+	client.cookie.Value = strings.Replace(client.cookie.Value, "|", "|A", 1)
+	if rec := client.do("GET", "/tcart/show"); rec.Body.String() != "<nil> []" {
+		t.Errorf("Tampered cookie has been accepted: %q", rec.Body.String())
+	}
+	if strings.Contains(client.cookie.Value, "count") {
+		t.Errorf("Cookie is not encrypted")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	testSessionStore(t, store)
+	if n := store.Backend.(*MemoryBackend).Len(); n != 0 {
+		t.Errorf("Expected rotated and destroyed sessions to be deleted, %d remaining", n)
+	}
+}
+
+func TestSessionHandler(t *testing.T) {
+	rt := NewRouter()
+	rt.SessionStore = NewMemoryStore()
+	rt.HandleFunc("/visit", nil, func(w http.ResponseWriter, r *http.Request) {
+		s := SessionFromContext(r.Context())
+		visits, _ := s.Get("visits").(int)
+		s.Set("visits", visits+1)
+		fmt.Fprint(w, visits+1)
+	})
+	client := &tSessionClient{t: t, router: rt}
+	client.do("GET", "/visit")
+	if rec := client.do("GET", "/visit"); rec.Body.String() != "2" {
+		t.Errorf("Expected 2 visits, got %q", rec.Body.String())
+	}
+}
+
+func TestSessionSaveError(t *testing.T) {
+	store, _ := NewCookieStore([]byte(strings.Repeat("k", 32)), nil)
+	var reported []error
+	rt := NewRouter()
+	rt.SessionStore = store
+	rt.SessionErrorHandler = func(h *http.Request, err error) { reported = append(reported, err) }
+	rt.HandleFunc("/large", nil, func(w http.ResponseWriter, r *http.Request) {
+		SessionFromContext(r.Context()).Set("data", strings.Repeat("x", 8192))
+		if r.URL.Query().Get("write") != "" {
+			w.Write([]byte("ok"))
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/large", nil))
+	if rec.Code != 500 || len(reported) != 1 || reported[0] != SessionTooLargeError {
+		t.Errorf("Expected 500 and reported error, got %d %v", rec.Code, reported)
+	}
+
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/large?write=1", nil))
+	if rec.Code != 200 || rec.Body.String() != "ok" || len(reported) != 2 {
+		t.Errorf("Expected written response and reported error, got %d %v", rec.Code, reported)
+	}
+}
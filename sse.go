@@ -0,0 +1,242 @@
+package wrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamEvent is an event sent by an EventStream
+type StreamEvent struct {
+	// ID is sent to the client as the event ID, which the client sends back in the Last-Event-ID header
+	// when reconnecting
+	ID string
+	// Event is the event type. If empty, the client dispatches a "message" event.
+	Event string
+	// Data is the payload. Strings and []byte are sent as they are, other values are encoded as JSON.
+	Data interface{}
+	// Retry when set, tells the client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+// StreamClosedError indicates that an event has been sent after the action owning the EventStream returned
+var StreamClosedError = errors.New("The event stream is closed")
+
+var (
+	streamEventType = reflect.TypeOf(StreamEvent{})
+	eventStreamType = reflect.TypeOf(&EventStream{})
+)
+
+// EventStream sends Server-Sent Events to the client. It can be injected into controller actions by declaring
+// an argument of type *wrouter.EventStream. The stream is opened by the first event or comment sent, which
+// writes the header and starts sending heartbeat comments (see Configuration.EventStream). Every event is
+// flushed immediately. Actions should return when Done is closed, i.e. the client has disconnected.
+//
+// Alternatively, actions can return a channel of StreamEvent, which is streamed by the router until it is
+// closed or the client disconnects.
+//
+// Handler timeouts (see Configuration.Limits) buffer the response, and therefore do not apply to actions
+// streaming events. Handlers registered by Router.Handle streaming events must not have a timeout.
+type EventStream struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	h         *http.Request
+	heartbeat time.Duration
+	opened    bool
+	closed    bool
+	stop      chan struct{}
+}
+
+func (r *Router) newEventStream(w http.ResponseWriter, h *http.Request) *EventStream {
+	return &EventStream{w: w, h: h, heartbeat: r.Configuration.EventStream.Heartbeat, stop: make(chan struct{})}
+}
+
+// LastEventID returns the ID of the last event received by a reconnecting client, or an empty string
+func (s *EventStream) LastEventID() string {
+	return s.h.Header.Get("Last-Event-ID")
+}
+
+// Done returns a channel which is closed when the client has disconnected
+func (s *EventStream) Done() <-chan struct{} {
+	return s.h.Context().Done()
+}
+
+// Send sends an event to the client. It returns an error, if the client has disconnected.
+func (s *EventStream) Send(event StreamEvent) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(int64(event.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	var data string
+	switch d := event.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	for _, line := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// SendData sends an event with the given data and no ID or type
+func (s *EventStream) SendData(data interface{}) error {
+	return s.Send(StreamEvent{Data: data})
+}
+
+// Comment sends a comment, which is ignored by the client, e.g. to keep the connection open
+func (s *EventStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// write opens the stream if necessary, and writes and flushes the message
+func (s *EventStream) write(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.h.Context().Err(); err != nil {
+		return err
+	}
+	if s.closed {
+		return StreamClosedError
+	}
+	if !s.opened {
+		s.open()
+	}
+	if _, err := fmt.Fprint(s.w, message); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+// open writes the header and starts the heartbeat. It is called with the lock held.
+func (s *EventStream) open() {
+	s.opened = true
+	header := s.w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Disables the buffering of proxies like nginx
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	s.w.WriteHeader(http.StatusOK)
+
+	if s.heartbeat > 0 {
+		go s.beat()
+	}
+}
+
+// beat sends heartbeat comments until the stream is closed or the client disconnects
+func (s *EventStream) beat() {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.h.Context().Done():
+			return
+		}
+	}
+}
+
+// close ends the stream after the action has returned. Further events are not sent.
+func (s *EventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+// streamsEvents returns true, if the route calls an action taking an EventStream or returning a channel of
+// StreamEvent
+func streamsEvents(route *Route) bool {
+	if route.Controller == nil || route.RMethod.Type == nil {
+		return false
+	}
+	t := route.RMethod.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if t.In(i) == eventStreamType {
+			return true
+		}
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		if isEventChannel(t.Out(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEventChannel returns true, if the type is a receivable channel of StreamEvent
+func isEventChannel(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0 && t.Elem() == streamEventType
+}
+
+// streamValues streams the first channel of StreamEvent returned by an action, until it is closed or the
+// client disconnects. It returns false, if the action has returned no such channel.
+func (r *Router) streamValues(w http.ResponseWriter, h *http.Request, values []reflect.Value) bool {
+	for _, v := range values {
+		if !isEventChannel(v.Type()) {
+			continue
+		}
+		stream := r.newEventStream(w, h)
+		defer stream.close()
+		if v.IsNil() {
+			return true
+		}
+
+		// The stream is opened before the first event, so that the client sees the connection established
+		stream.Comment("stream")
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: v},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(h.Context().Done())},
+		}
+		for {
+			chosen, event, ok := reflect.Select(cases)
+			if chosen == 1 || !ok {
+				return true
+			}
+			if stream.Send(event.Interface().(StreamEvent)) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// singleLine removes line breaks, which would end a field of an event
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
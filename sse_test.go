@@ -0,0 +1,99 @@
+package wrouter
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tFeedController struct {
+	kept *EventStream
+}
+
+func (t *tFeedController) KeepAction(stream *EventStream) {
+	t.kept = stream
+	stream.SendData("kept")
+}
+
+func (t *tFeedController) LiveAction(stream *EventStream) {
+	stream.Send(StreamEvent{ID: "7", Event: "update", Data: "line 1\nline 2", Retry: 2 * time.Second})
+	stream.SendData(map[string]int{"after": len(stream.LastEventID())})
+	select {
+	case <-stream.Done():
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func (t *tFeedController) ChannelAction() <-chan StreamEvent {
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for _, id := range []string{"1", "2"} {
+			events <- StreamEvent{ID: id, Data: id}
+		}
+	}()
+	return events
+}
+
+func (t *tFeedController) EndlessAction() chan StreamEvent {
+	return make(chan StreamEvent)
+}
+
+func TestEventStream(t *testing.T) {
+	controller := &tFeedController{}
+	rt := NewRouter()
+	rt.Configuration.EventStream.Heartbeat = 10 * time.Millisecond
+	// Streams are not buffered by handler timeouts
+	rt.Configuration.Limits.Timeout = 5 * time.Millisecond
+	rt.AddRenderer("json", new(JSONRenderer))
+	rt.AddController(controller)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/tfeed/live", nil)
+	req.Header.Set("Last-Event-ID", "42")
+	rt.ServeHTTP(rec, req)
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "text/event-stream" ||
+		rec.Header().Get("Cache-Control") != "no-cache" || !rec.Flushed {
+		t.Fatalf("unexpected response: %d %v", rec.Code, rec.Header())
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "id: 7\nevent: update\nretry: 2000\ndata: line 1\ndata: line 2\n\n"+
+		"data: {\"after\":2}\n\n") {
+		t.Errorf("unexpected events: %q", body)
+	}
+	if !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("expected heartbeat in %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/tfeed/channel", nil))
+	if rec.Header().Get("Content-Type") != "text/event-stream" ||
+		!strings.HasSuffix(rec.Body.String(), "id: 1\ndata: 1\n\nid: 2\ndata: 2\n\n") {
+		t.Errorf("unexpected channel stream: %v %q", rec.Header(), rec.Body.String())
+	}
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tfeed/keep", nil))
+	if err := controller.kept.SendData("late"); err != StreamClosedError {
+		t.Errorf("Expected StreamClosedError after the action returned, got %v", err)
+	}
+}
+
+func TestEventStreamDisconnect(t *testing.T) {
+	rt := NewRouter()
+	rt.AddController(&tFeedController{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tfeed/endless", nil).WithContext(ctx))
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream not ended by disconnected client")
+	}
+}
@@ -0,0 +1,251 @@
+package wrouter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StaticOptions contains the settings of a static file route. See Router.Static and Router.StaticFS
+type StaticOptions struct {
+	// Index is the file served for a directory. An empty string disables index files.
+	//
+	// Default: index.html
+	Index string
+
+	// SPAFallback when set to true, will serve the root index file for every path without file extension
+	// which does not exist, so that client-side routed single page applications can be served.
+	//
+	// Default: false
+	SPAFallback bool
+
+	// ListDirectories when set to true, will render a listing of directories without an index file. If set
+	// to false, such directories are answered with a 404.
+	//
+	// Default: false
+	ListDirectories bool
+
+	// Precompressed when set to true, will serve a file.br or file.gz variant instead of file, if it exists
+	// and the client accepts the encoding.
+	//
+	// Default: false
+	Precompressed bool
+
+	// CacheControl is the value of the Cache-Control header set on every served file. An empty string does
+	// not set the header.
+	//
+	// Default: ""
+	CacheControl string
+}
+
+// DefaultStaticOptions returns the default options of a static file route
+func DefaultStaticOptions() *StaticOptions {
+	return &StaticOptions{Index: "index.html"}
+}
+
+// precompressedEncodings are the encodings searched for precompressed variants, in order of preference
+var precompressedEncodings = []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+
+// Static serves the files of the given directory below prefix. See StaticFS
+func (r *Router) Static(prefix, dir string, opts *StaticOptions) *Route {
+	return r.StaticFS(prefix, os.DirFS(dir), opts)
+}
+
+// StaticFS serves the files of the given fs.FS, e.g. an embed.FS, below prefix. Files are served with
+// ETag and Last-Modified validators and support conditional and range requests. Missing files are answered
+// through the router's error handling. If opts is nil, DefaultStaticOptions are used.
+func (r *Router) StaticFS(prefix string, fsys fs.FS, opts *StaticOptions) *Route {
+	route := r.newStaticRoute(prefix, fsys, opts)
+	r.AddRoute(route)
+	return route
+}
+
+func (r *Router) newStaticRoute(prefix string, fsys fs.FS, opts *StaticOptions) *Route {
+	if opts == nil {
+		opts = DefaultStaticOptions()
+	}
+
+	route := new(Route)
+	route.Prefix = true
+	route.Path = normalizePath(prefix)
+	route.Handler = &staticHandler{router: r, fsys: fsys, options: opts, route: route}
+	route.AddMethod("GET")
+	route.AddMethod("HEAD")
+	return route
+}
+
+type staticHandler struct {
+	router  *Router
+	fsys    fs.FS
+	options *StaticOptions
+	route   *Route
+
+	// etags caches content based ETags of files without modification time, e.g. of an embed.FS
+	etags sync.Map
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *staticHandler) ServeHTTP(w http.ResponseWriter, h *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+h.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		// Single page applications route on the client side; only paths which look like files are missing
+		if s.options.SPAFallback && s.options.Index != "" && path.Ext(name) == "" {
+			if _, err := fs.Stat(s.fsys, s.options.Index); err == nil {
+				s.serveFile(w, h, s.options.Index)
+				return
+			}
+		}
+		s.router.serveError(w, h, http.StatusNotFound)
+		return
+	}
+
+	if info.IsDir() {
+		if s.options.Index != "" {
+			index := path.Join(name, s.options.Index)
+			if fi, err := fs.Stat(s.fsys, index); err == nil && !fi.IsDir() {
+				s.serveFile(w, h, index)
+				return
+			}
+		}
+		if s.options.ListDirectories {
+			s.serveDirectory(w, h, name)
+			return
+		}
+		s.router.serveError(w, h, http.StatusNotFound)
+		return
+	}
+
+	s.serveFile(w, h, name)
+}
+
+// serveFile serves one file, or its precompressed variant, using http.ServeContent for conditional and
+// range requests
+func (s *staticHandler) serveFile(w http.ResponseWriter, h *http.Request, name string) {
+	served := name
+	if s.options.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		qualities := parseAcceptEncoding(h.Header.Get("Accept-Encoding"))
+		for _, pe := range precompressedEncodings {
+			if encodingQuality(qualities, pe.encoding) <= 0 {
+				continue
+			}
+			if fi, err := fs.Stat(s.fsys, name+pe.ext); err == nil && !fi.IsDir() {
+				served = name + pe.ext
+				w.Header().Set("Content-Encoding", pe.encoding)
+				break
+			}
+		}
+	}
+
+	f, err := s.fsys.Open(served)
+	if err != nil {
+		s.router.serveError(w, h, http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		s.router.serveError(w, h, http.StatusInternalServerError)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			s.router.serveError(w, h, http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	// The content type is derived from the original name, not from the precompressed variant
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	if s.options.CacheControl != "" {
+		w.Header().Set("Cache-Control", s.options.CacheControl)
+	}
+	if etag := s.etag(served, info, content); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	http.ServeContent(w, h, name, info.ModTime(), content)
+}
+
+// etag returns a strong ETag for the file. It is derived from the modification time and size, or from the
+// content if the file system does not provide modification times.
+func (s *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return "\"" + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" +
+			strconv.FormatInt(info.Size(), 36) + "\""
+	}
+
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return ""
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	etag := "\"" + hex.EncodeToString(hash.Sum(nil)[:16]) + "\""
+	s.etags.Store(name, etag)
+	return etag
+}
+
+// serveDirectory renders a simple HTML listing of a directory
+func (s *staticHandler) serveDirectory(w http.ResponseWriter, h *http.Request, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		s.router.serveError(w, h, http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	base := "/" + s.route.Path
+	if name != "." {
+		base = path.Join(base, name)
+	}
+	base = strings.TrimSuffix(base, "/") + "/"
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta charset=\"utf-8\">\n<title>" + html.EscapeString(base) +
+		"</title>\n<h1>" + html.EscapeString(base) + "</h1>\n<ul>\n")
+	for _, entry := range entries {
+		display := entry.Name()
+		if entry.IsDir() {
+			display += "/"
+		}
+		href := base + (&url.URL{Path: entry.Name()}).EscapedPath()
+		b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(display) +
+			"</a></li>\n")
+	}
+	b.WriteString("</ul>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.Method == "HEAD" {
+		return
+	}
+	io.WriteString(w, b.String())
+}
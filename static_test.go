@@ -0,0 +1,94 @@
+package wrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStaticFS(t *testing.T) {
+	modTime := time.Date(2017, 6, 4, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<h1>app</h1>")},
+		"css/app.css":       {Data: []byte("body{}"), ModTime: modTime},
+		"css/app.css.gz":    {Data: []byte("gzipped"), ModTime: modTime},
+		"docs/Readme.txt":   {Data: []byte("0123456789")},
+		"docs/sub/file.txt": {Data: []byte("file")},
+	}
+
+	rt := NewRouter()
+	opts := DefaultStaticOptions()
+	opts.SPAFallback = true
+	opts.ListDirectories = true
+	opts.Precompressed = true
+	rt.StaticFS("/assets", fsys, opts)
+	rt.ErrorHandler = ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, status int) {
+		w.WriteHeader(status)
+		w.Write([]byte("custom error"))
+	})
+
+	serve := func(method, uri string, header map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, uri, nil)
+		for k, v := range header {
+			request.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, request)
+		return rec
+	}
+
+	rec := serve("GET", "/assets/css/app.css", nil)
+	if rec.Code != 200 || rec.Body.String() != "body{}" || rec.Header().Get("Last-Modified") == "" ||
+		!strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Unexpected response for plain file: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	etag := rec.Header().Get("ETag")
+
+	rec = serve("GET", "/assets/css/app.css", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", rec.Code)
+	}
+
+	rec = serve("GET", "/assets/css/app.css", map[string]string{"Accept-Encoding": "br;q=0, gzip"})
+	if rec.Body.String() != "gzipped" || rec.Header().Get("Content-Encoding") != "gzip" ||
+		!strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expected precompressed variant, got %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = serve("GET", "/assets/css/app.css", map[string]string{"Accept-Encoding": "br;q=0, *"})
+	if rec.Body.String() != "gzipped" || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected precompressed variant for wildcard, got %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = serve("GET", "/assets/css/app.css", map[string]string{"Accept-Encoding": "*;q=0"})
+	if rec.Body.String() != "body{}" || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected plain file for refused encodings, got %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = serve("GET", "/assets/docs/Readme.txt", map[string]string{"Range": "bytes=2-4"})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" || rec.Header().Get("ETag") == "" {
+		t.Errorf("Unexpected range response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = serve("GET", "/assets/docs/", nil)
+	if !strings.Contains(rec.Body.String(), "href=\"/assets/docs/Readme.txt\"") ||
+		!strings.Contains(rec.Body.String(), "sub/") {
+		t.Errorf("Unexpected directory listing: %s", rec.Body.String())
+	}
+
+	if rec = serve("GET", "/assets/some/client/route", nil); rec.Body.String() != "<h1>app</h1>" {
+		t.Errorf("Expected SPA fallback, got %q", rec.Body.String())
+	}
+	if rec = serve("GET", "/assets", nil); rec.Body.String() != "<h1>app</h1>" {
+		t.Errorf("Expected index file, got %q", rec.Body.String())
+	}
+	if rec = serve("GET", "/assets/missing.js", nil); rec.Code != 404 || rec.Body.String() != "custom error" {
+		t.Errorf("Expected 404 through the error handler, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec = serve("POST", "/assets/css/app.css", nil); rec.Code != 404 {
+		t.Errorf("Expected 404 for POST, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,298 @@
+package wrouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader and TraceStateHeader are the headers defined by the W3C Trace Context specification
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// InvalidTraceParentError indicates that a traceparent header could not be parsed
+var InvalidTraceParentError = errors.New("Invalid traceparent header")
+
+// TraceID identifies a whole trace
+type TraceID [16]byte
+
+// SpanID identifies one span of a trace
+type SpanID [8]byte
+
+// String returns the lowercase hex representation of the TraceID
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// String returns the lowercase hex representation of the SpanID
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext contains the identifying, propagated part of a span, as defined by the W3C Trace Context.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Flags contains the trace flags. Currently, only the sampled flag (0x01) is defined.
+	Flags byte
+	// TraceState contains the vendor specific tracestate header, which is propagated as is.
+	TraceState string
+}
+
+// IsValid returns true, if neither the TraceID nor the SpanID are all zeros
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent returns the traceparent header value of the SpanContext
+func (sc SpanContext) TraceParent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceParent parses a traceparent and tracestate header value into a SpanContext. The tracestate is
+// taken as is.
+func ParseTraceParent(traceparent, tracestate string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, InvalidTraceParentError
+	}
+	// Version 00 has exactly four fields; future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, InvalidTraceParentError
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, InvalidTraceParentError
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, InvalidTraceParentError
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, InvalidTraceParentError
+	}
+	flags := make([]byte, 1)
+	if _, err := hex.Decode(flags, []byte(parts[3])); err != nil {
+		return sc, InvalidTraceParentError
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, InvalidTraceParentError
+	}
+	sc.TraceState = strings.TrimSpace(tracestate)
+	return sc, nil
+}
+
+// Span represents one timed operation of a trace
+type Span interface {
+	// SpanContext returns the propagated context of the span
+	SpanContext() SpanContext
+	// SetAttribute sets an attribute on the span
+	SetAttribute(key string, value interface{})
+	// SetError marks the span as failed
+	SetError(err error)
+	// End finishes the span. Calls after the first call are ignored.
+	End()
+}
+
+// Tracer is a tiny interface for tracing. It can be used to plug in a custom tracing implementation.
+// See: Router.Tracer
+type Tracer interface {
+	// Start creates a new span as child of the span in the given context, or as child of a remote span
+	// context created by ContextWithRemoteSpanContext. The returned context contains the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// SpanData contains all recorded information of a finished span. It is passed to a SpanExporter.
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+	// Parent contains the SpanID of the parent span. It is all zeros for root spans.
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	// Error contains the error message, if the span has been marked as failed
+	Error string
+}
+
+// SpanExporter receives every finished span of a Tracer created by NewTracer
+type SpanExporter interface {
+	ExportSpan(*SpanData)
+}
+
+type spanContextKey struct{}
+type remoteSpanContextKey struct{}
+
+// SpanFromContext returns the current span of the context, or nil if there is none. Within a controller
+// action, the span of the action can be retrieved by the context of the *http.Request.
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanContextKey{}).(Span)
+	return span
+}
+
+// ContextWithSpan returns a copy of the context containing the given span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// ContextWithRemoteSpanContext returns a copy of the context containing a span context received from a
+// remote service. The next started span will be its child.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// ExtractTraceContext parses the W3C trace context headers of a request into the returned context. If the
+// headers are missing or invalid, the context is returned unchanged.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceParent(header.Get(TraceParentHeader), header.Get(TraceStateHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// InjectTraceContext sets the W3C trace context headers of the current span in the given context on the
+// header. It is used to propagate the trace to outgoing requests.
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil || !span.SpanContext().IsValid() {
+		return
+	}
+	sc := span.SpanContext()
+	header.Set(TraceParentHeader, sc.TraceParent())
+	if sc.TraceState != "" {
+		header.Set(TraceStateHeader, sc.TraceState)
+	}
+}
+
+// parentSpanContext returns the span context of the current span, or the remote span context
+func parentSpanContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext(), true
+	}
+	sc, ok := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// NewTracer creates the default Tracer, which passes every finished span to the given SpanExporter.
+// All spans are sampled, unless the remote parent was explicitly not sampled.
+func NewTracer(exporter SpanExporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+type tracer struct {
+	exporter SpanExporter
+}
+
+// Start implements the Tracer interface
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := new(span)
+	s.tracer = t
+	s.data.Name = name
+	s.data.Start = time.Now()
+	s.data.SpanContext.Flags = 0x01
+
+	if parent, ok := parentSpanContext(ctx); ok && parent.IsValid() {
+		s.data.SpanContext.TraceID = parent.TraceID
+		s.data.SpanContext.Flags = parent.Flags
+		s.data.SpanContext.TraceState = parent.TraceState
+		s.data.Parent = parent.SpanID
+	} else {
+		rand.Read(s.data.SpanContext.TraceID[:])
+	}
+	rand.Read(s.data.SpanContext.SpanID[:])
+
+	return ContextWithSpan(ctx, s), s
+}
+
+type span struct {
+	mu     sync.Mutex
+	tracer *tracer
+	data   SpanData
+	ended  bool
+}
+
+func (s *span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+func (s *span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+
+	// The exported data is a snapshot, attributes may still be set on the span concurrently
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for key, value := range s.data.Attributes {
+		data.Attributes[key] = value
+	}
+	s.mu.Unlock()
+
+	// Spans of not sampled traces are recorded, but not exported
+	if data.SpanContext.Flags&0x01 != 0 && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(&data)
+	}
+}
+
+// noopSpan is used by the router, if no Tracer is configured
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext             { return SpanContext{} }
+func (noopSpan) SetAttribute(_ string, _ interface{}) {}
+func (noopSpan) SetError(_ error)                     {}
+func (noopSpan) End()                                 {}
+
+// MemoryExporter is a SpanExporter keeping all finished spans in memory. It is meant for tests and debugging.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+// ExportSpan implements the SpanExporter interface
+func (e *MemoryExporter) ExportSpan(s *SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+}
+
+// Spans returns all exported spans in the order they have been finished
+func (e *MemoryExporter) Spans() []*SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]*SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset removes all exported spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
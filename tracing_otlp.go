@@ -0,0 +1,208 @@
+package wrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// OTLPExporter is a SpanExporter sending spans in the OTLP/HTTP JSON encoding to an OpenTelemetry collector
+// or any other OTLP compatible backend. Spans are sent in batches; call Flush before shutting down the
+// application to send the remaining spans.
+type OTLPExporter struct {
+	// Endpoint is the full URL of the traces endpoint, e.g. http://localhost:4318/v1/traces
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// Headers are added to every export request, e.g. for authentication
+	Headers http.Header
+	// Client is the HTTP client used for exporting. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// BatchSize is the amount of spans which triggers an export in the background. If 0, 512 is used.
+	BatchSize int
+	// OnError is called with the errors of exports running in the background. If nil, errors are discarded.
+	OnError func(error)
+
+	mu    sync.Mutex
+	batch []*SpanData
+}
+
+// NewOTLPExporter creates an OTLPExporter for the given endpoint and service name
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{Endpoint: endpoint, ServiceName: serviceName}
+}
+
+// ExportSpan implements the SpanExporter interface
+func (e *OTLPExporter) ExportSpan(s *SpanData) {
+	size := e.BatchSize
+	if size <= 0 {
+		size = 512
+	}
+
+	e.mu.Lock()
+	e.batch = append(e.batch, s)
+	full := len(e.batch) >= size
+	e.mu.Unlock()
+
+	if full {
+		go func() {
+			if err := e.Flush(); err != nil && e.OnError != nil {
+				e.OnError(err)
+			}
+		}()
+	}
+}
+
+// Flush sends all buffered spans to the endpoint
+func (e *OTLPExporter) Flush() error {
+	e.mu.Lock()
+	spans := e.batch
+	e.batch = nil
+	e.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range e.Headers {
+		for _, v := range values {
+			request.Header.Add(key, v)
+		}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New("OTLP export failed with status " + response.Status)
+	}
+	return nil
+}
+
+// The types below mirror the JSON encoding of the OTLP ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpStatusError      = 2
+)
+
+func (e *OTLPExporter) encode(spans []*SpanData) *otlpRequest {
+	scope := otlpScopeSpans{}
+	scope.Scope.Name = "github.com/DanieleDaccurso/wrouter"
+
+	for _, s := range spans {
+		sp := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			TraceState:        s.SpanContext.TraceState,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.Parent != (SpanID{}) {
+			sp.ParentSpanID = s.Parent.String()
+		}
+		if kind, ok := s.Attributes["span.kind"]; ok && kind == "server" {
+			sp.Kind = otlpSpanKindServer
+		}
+		for key, value := range s.Attributes {
+			if key != "span.kind" {
+				sp.Attributes = append(sp.Attributes, otlpAttr(key, value))
+			}
+		}
+		if s.Error != "" {
+			sp.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		scope.Spans = append(scope.Spans, sp)
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", e.ServiceName)}},
+			ScopeSpans: []otlpScopeSpans{scope},
+		}},
+	}
+}
+
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	a := otlpAttribute{Key: key, Value: make(map[string]interface{})}
+	switch v := value.(type) {
+	case string:
+		a.Value["stringValue"] = v
+	case bool:
+		a.Value["boolValue"] = v
+	case int:
+		a.Value["intValue"] = strconv.Itoa(v)
+	case int64:
+		a.Value["intValue"] = strconv.FormatInt(v, 10)
+	case float64:
+		a.Value["doubleValue"] = v
+	default:
+		a.Value["stringValue"] = fmt.Sprint(v)
+	}
+	return a
+}
@@ -0,0 +1,94 @@
+package wrouter
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" ||
+		sc.Flags != 1 || sc.TraceState != "congo=t61rcWkgMzE" {
+		t.Errorf("Unexpected span context %+v", sc)
+	}
+	if sc.TraceParent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Unexpected traceparent %s", sc.TraceParent())
+	}
+
+	invalid := []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-00",
+	}
+	for _, tp := range invalid {
+		if _, err := ParseTraceParent(tp, ""); err == nil {
+			t.Errorf("Expected traceparent %q to be invalid", tp)
+		}
+	}
+}
+
+func TestRouterTracing(t *testing.T) {
+	exporter := new(MemoryExporter)
+	rt := NewRouter()
+	rt.Tracer = NewTracer(exporter)
+	rt.AddController(&tMockController{})
+	rt.AddInjector(new(tMockUserInjector))
+
+	request := httptest.NewRequest("DELETE", "/tmock/tsub/user", nil)
+	request.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rt.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := exporter.Spans()
+	names := make(map[string]*SpanData)
+	for _, s := range spans {
+		names[s.Name] = s
+		if s.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Span %s was not continued from the incoming traceparent", s.Name)
+		}
+	}
+
+	for _, name := range []string{"HTTP DELETE", "routing", "action", "inject *wrouter.tMockUser"} {
+		if names[name] == nil {
+			t.Fatalf("Expected span %q, got %d spans", name, len(spans))
+		}
+	}
+	if names["HTTP DELETE"].Parent.String() != "00f067aa0ba902b7" {
+		t.Error("Server span has the wrong parent")
+	}
+	if names["inject *wrouter.tMockUser"].Parent != names["action"].SpanContext.SpanID {
+		t.Error("Injector span is not a child of the action span")
+	}
+	if names["HTTP DELETE"].Attributes["http.route"] != "/tmock/tsub/user" {
+		t.Error("Server span is missing the matched route")
+	}
+}
+
+func TestSpanExportSnapshot(t *testing.T) {
+	exporter := new(MemoryExporter)
+	_, span := NewTracer(exporter).Start(context.Background(), "work")
+	span.SetAttribute("before", true)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			span.SetAttribute(fmt.Sprint("late", i), i)
+		}
+	}()
+	span.End()
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Attributes["before"] != true {
+		t.Fatalf("Expected exported span with attributes, got %v", spans)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,226 @@
+package wrouter
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metadata keys for view rendering
+const (
+	// MetaView is the metadata key of the view rendering a route, overriding the view found by convention,
+	// e.g. "user/profile"
+	MetaView = "view"
+	// MetaLayout is the metadata key of the layout of a route, overriding Views.Layout. "none" renders the
+	// view without layout.
+	MetaLayout = "view.layout"
+)
+
+var (
+	// UnknownActionError indicates that no route exists for the action passed to Router.URL
+	UnknownActionError = errors.New("No route exists for the action")
+	// OddQueryError indicates that a query parameter passed to Router.URL has no value
+	OddQueryError = errors.New("The query must consist of name and value pairs")
+)
+
+// Views is a Renderer for text/html, rendering the value returned by an action with the html/template found by
+// the names of the controller and the action, e.g. "user/show.html" for UserController.ShowAction as well as
+// UserController.Post_ShowAction. The view is executed inside the layout, which includes
+// it by {{template "content" .}}. Views may define further blocks used by the layout, e.g. {{define "title"}}.
+// Partials are available to all views by their path, e.g. {{template "partials/nav.html" .}}.
+//
+// The FuncMap of all templates contains the function "url", which returns the path of an action, see
+// Router.URL.
+type Views struct {
+	// FS contains the templates, e.g. os.DirFS("views") or an embed.FS
+	FS fs.FS
+	// Extension is the file extension of the templates
+	//
+	// Default: .html
+	Extension string
+	// Layout is the path of the layout template. If empty, views are rendered without layout.
+	Layout string
+	// Partials is a glob pattern matching the partial templates
+	//
+	// Default: partials/*.html
+	Partials string
+	// Funcs contains functions available to all templates, in addition to the built-in functions
+	Funcs template.FuncMap
+	// Reload when set to true, reparses templates whose files have changed since they have been parsed. It is
+	// meant for development.
+	Reload bool
+
+	router *Router
+	mu     sync.Mutex
+	cache  map[string]*parsedView
+}
+
+// parsedView is a parsed view, including the files it has been parsed from
+type parsedView struct {
+	template *template.Template
+	files    map[string]time.Time
+}
+
+// NewViews creates a Views renderer for the templates in fsys. The router is used by the "url" function.
+func NewViews(fsys fs.FS, router *Router) *Views {
+	return &Views{FS: fsys, Extension: ".html", Partials: "partials/*.html", router: router,
+		cache: make(map[string]*parsedView)}
+}
+
+// MediaType implements the Renderer interface
+func (v *Views) MediaType() string { return "text/html" }
+
+// Render implements the Renderer interface
+func (v *Views) Render(w io.Writer, ctx *RenderContext) error {
+	name := ctx.Route.Metadata.String(MetaView)
+	if name == "" {
+		name = viewName(ctx.Route)
+	}
+	layout := v.Layout
+	if l, ok := ctx.Route.Metadata[MetaLayout]; ok {
+		layout, _ = l.(string)
+		if layout == "none" {
+			layout = ""
+		}
+	}
+
+	view, err := v.view(name, layout)
+	if err != nil {
+		return err
+	}
+	if layout != "" {
+		return view.template.ExecuteTemplate(w, "layout", ctx.Value)
+	}
+	return view.template.ExecuteTemplate(w, "content", ctx.Value)
+}
+
+// viewName returns the conventional view name of a controller route, e.g. "user/show", or "admin/user/show"
+// for the sub-controller of an AdminController
+func viewName(route *Route) string {
+	if route.Controller == nil {
+		return ""
+	}
+	if route.actionPath != "" {
+		return route.actionPath
+	}
+
+	// Routes of custom resolvers
+	action := strings.ToLower(route.RMethod.Name)
+	if i := strings.Index(action, "_"); i >= 0 {
+		action = action[i+1:]
+	}
+	action = strings.Replace(action, "action", "", -1)
+	return controllerPath(route.Controller) + "/" + action
+}
+
+// view returns the parsed view, parsing it on first use or if it has changed in reload mode
+func (v *Views) view(name, layout string) (*parsedView, error) {
+	key := name + "|" + layout
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cache == nil {
+		v.cache = make(map[string]*parsedView)
+	}
+	if view, ok := v.cache[key]; ok && (!v.Reload || !v.changed(view)) {
+		return view, nil
+	}
+
+	view, err := v.parse(name, layout)
+	if err != nil {
+		return nil, err
+	}
+	v.cache[key] = view
+	return view, nil
+}
+
+// changed returns true, if one of the files of the view has been modified
+func (v *Views) changed(view *parsedView) bool {
+	for file, modTime := range view.files {
+		info, err := fs.Stat(v.FS, file)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse parses the view, its partials and the layout into one template set
+func (v *Views) parse(name, layout string) (*parsedView, error) {
+	ext := v.Extension
+	if ext == "" {
+		ext = ".html"
+	}
+	view := &parsedView{files: make(map[string]time.Time)}
+	t := template.New("").Funcs(template.FuncMap{"url": v.url}).Funcs(v.Funcs)
+
+	// The view is parsed last, so that its blocks replace the defaults of the layout
+	var files []struct{ name, file string }
+	if layout != "" {
+		files = append(files, struct{ name, file string }{"layout", layout})
+	}
+	if v.Partials != "" {
+		partials, err := fs.Glob(v.FS, v.Partials)
+		if err != nil {
+			return nil, err
+		}
+		for _, partial := range partials {
+			files = append(files, struct{ name, file string }{partial, partial})
+		}
+	}
+	files = append(files, struct{ name, file string }{"content", name + ext})
+
+	for _, f := range files {
+		b, err := fs.ReadFile(v.FS, f.file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(f.name).Parse(string(b)); err != nil {
+			return nil, err
+		}
+		if info, err := fs.Stat(v.FS, f.file); err == nil {
+			view.files[f.file] = info.ModTime()
+		}
+	}
+	view.template = t
+	return view, nil
+}
+
+// url is the "url" template function
+func (v *Views) url(action string, query ...string) (string, error) {
+	if v.router == nil {
+		return "", UnknownActionError
+	}
+	return v.router.URL(action, query...)
+}
+
+// URL returns the path of the route calling the action, e.g. "UserController.ShowAction", followed by the
+// query built from the given name and value pairs. Prefix routes end in "/". It can be used to create links
+// to actions without repeating their paths. An odd number of query arguments returns OddQueryError.
+func (r *Router) URL(action string, query ...string) (string, error) {
+	for _, route := range r.routes {
+		if route.actionName() != action {
+			continue
+		}
+		u := "/" + route.Path
+		if route.Prefix && !strings.HasSuffix(u, "/") {
+			u += "/"
+		}
+		if len(query)%2 != 0 {
+			return "", OddQueryError
+		}
+		if len(query) != 0 {
+			values := url.Values{}
+			for i := 0; i < len(query); i += 2 {
+				values.Add(query[i], query[i+1])
+			}
+			u += "?" + values.Encode()
+		}
+		return u, nil
+	}
+	return "", UnknownActionError
+}
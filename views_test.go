@@ -0,0 +1,90 @@
+package wrouter
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type tProfileController struct {
+	_ Meta `action:"BareAction" view.layout:"none" view:"tprofile/show"`
+}
+
+type tTeamController struct {
+	Members *tMemberController
+}
+
+type tMemberController struct{}
+
+func (t *tMemberController) ShowAction() *tProfile { return &tProfile{"dave"} }
+
+type tProfile struct {
+	Name string
+}
+
+func (t *tProfileController) ShowAction() *tProfile { return &tProfile{"<alice>"} }
+func (t *tProfileController) BareAction() *tProfile { return &tProfile{"bob"} }
+func (t *tProfileController) EditAction() *tProfile { return &tProfile{"carol"} }
+
+func TestViews(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/main.html": {Data: []byte(
+			`<title>{{block "title" .}}Default{{end}}</title>{{template "partials/nav.html" .}}{{template "content" .}}`)},
+		"partials/nav.html":       {Data: []byte(`<a href="{{url "tProfileController.EditAction" "name" .Name}}">edit</a>`)},
+		"tteam/tmember/show.html": {Data: []byte(`<b>{{.Name}}</b>`)},
+		"tmember/show.html":       {Data: []byte(`{{.Undefined}}`)},
+		"tprofile/show.html": {Data: []byte(`{{define "title"}}Profile{{end}}<h1>{{.Name | upper}}</h1>`),
+			ModTime: time.Unix(1, 0)},
+	}
+	rt := NewRouter()
+	views := NewViews(fsys, rt)
+	views.Layout = "layouts/main.html"
+	views.Funcs = map[string]interface{}{"upper": strings.ToUpper}
+	views.Reload = true
+
+	rt.AddRenderer("html", views)
+	rt.AddController(&tProfileController{})
+	rt.AddController(&tTeamController{Members: &tMemberController{}})
+	rt.AddController(&tMemberController{})
+
+	render := func(uri string) (int, string) {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, body := render("/tprofile/show"); code != 200 || body !=
+		`<title>Profile</title><a href="/tprofile/edit?name=%3Calice%3E">edit</a><h1>&lt;ALICE&gt;</h1>` {
+		t.Errorf("Unexpected view %d %q", code, body)
+	}
+	if code, body := render("/tprofile/bare"); code != 200 || body != `<h1>BOB</h1>` {
+		t.Errorf("Unexpected view without layout %d %q", code, body)
+	}
+	// Views of sub-controllers are found below the path of the parent controller
+	if code, body := render("/tteam/tmember/show"); code != 200 || !strings.HasSuffix(body, `<b>dave</b>`) {
+		t.Errorf("Unexpected sub-controller view %d %q", code, body)
+	}
+	if code, _ := render("/tmember/show"); code != 500 {
+		t.Errorf("Expected 500 for missing view, got %d", code)
+	}
+	if code, _ := render("/tprofile/edit"); code != 500 {
+		t.Errorf("Expected 500 for missing view, got %d", code)
+	}
+
+	fsys["tprofile/show.html"] = &fstest.MapFile{Data: []byte(`<p>{{.Name}}</p>`), ModTime: time.Unix(2, 0)}
+	if _, body := render("/tprofile/bare"); body != `<p>bob</p>` {
+		t.Errorf("Expected reloaded view, got %q", body)
+	}
+
+	if u, err := rt.URL("tProfileController.ShowAction"); err != nil || u != "/tprofile/show" {
+		t.Errorf("Unexpected URL %q %v", u, err)
+	}
+	if _, err := rt.URL("tProfileController.ShowAction", "name", "alice", "tab"); err != OddQueryError {
+		t.Errorf("Expected OddQueryError, got %v", err)
+	}
+	if _, err := rt.URL("tProfileController.MissingAction"); err != UnknownActionError {
+		t.Errorf("Expected UnknownActionError, got %v", err)
+	}
+}